@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+func (app *application) listCreditsHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	credits, err := app.models.Credits.GetAllForMovie(movieID)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"credits": credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) createCreditHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	var input struct {
+		PersonID  int64  `json:"person_id"`
+		Role      string `json:"role"`
+		Character string `json:"character"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	credit := &data.Credit{
+		MovieID:   movieID,
+		PersonID:  input.PersonID,
+		Role:      input.Role,
+		Character: input.Character,
+	}
+
+	v := validator.New()
+
+	if data.ValidateCredit(v, credit); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	_, err = app.models.People.Get(credit.PersonID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("person_id", "no matching person found")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.models.Credits.Insert(credit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "this person is already credited in this role")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusCreated, envelope{"credit": credit}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) deleteCreditHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	creditID, err := app.readNamedIDParameter(request, "credit_id")
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	err = app.models.Credits.Delete(movieID, creditID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"message": "credit successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
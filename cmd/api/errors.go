@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 func (app *application) logError(request *http.Request, err error) {
@@ -51,6 +52,26 @@ func (app *application) editConflictResponse(writer http.ResponseWriter, request
 	app.errorResponse(writer, request, http.StatusConflict, message)
 }
 
+func (app *application) duplicateMovieResponse(writer http.ResponseWriter, request *http.Request, ids []int64) {
+	message := "a movie with a similar title from the same year exists already, set allow_duplicate=true to create it anyway"
+	env := envelope{"error:": message, "duplicate_ids": ids}
+	err := app.writeJSON(writer, http.StatusConflict, env, nil)
+	if err != nil {
+		app.logError(request, err)
+		writer.WriteHeader(500)
+	}
+}
+
+func (app *application) preconditionFailedResponse(writer http.ResponseWriter, request *http.Request) {
+	message := "the resource has been modified since you last retrieved it, please fetch it again"
+	app.errorResponse(writer, request, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(writer http.ResponseWriter, request *http.Request) {
+	message := "this request must be made conditional with an If-Match header"
+	app.errorResponse(writer, request, http.StatusPreconditionRequired, message)
+}
+
 func (app *application) rateLimitExceededResponse(writer http.ResponseWriter, request *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(writer, request, http.StatusTooManyRequests, message)
@@ -81,3 +102,18 @@ func (app *application) notPermittedResponse(writer http.ResponseWriter, request
 	message := "your user account does not have the necessary permissions to access this resource"
 	app.errorResponse(writer, request, http.StatusForbidden, message)
 }
+
+func (app *application) unsupportedMediaTypeResponse(writer http.ResponseWriter, request *http.Request, supported ...string) {
+	message := fmt.Sprintf("the content type must be one of: %s", strings.Join(supported, ", "))
+	app.errorResponse(writer, request, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) idempotencyKeyInUseResponse(writer http.ResponseWriter, request *http.Request) {
+	message := "a request with this idempotency key is still being processed, please try again later"
+	app.errorResponse(writer, request, http.StatusConflict, message)
+}
+
+func (app *application) idempotencyKeyMismatchResponse(writer http.ResponseWriter, request *http.Request) {
+	message := "this idempotency key has already been used for a different request"
+	app.errorResponse(writer, request, http.StatusUnprocessableEntity, message)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+const (
+	// exportBatchSize movies are written within exportWriteTimeout each, which
+	// stops exports to clients that don't read them anymore
+	exportBatchSize    = 100
+	exportWriteTimeout = 30 * time.Second
+)
+
+var exportContentTypes = map[string]string{
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv",
+}
+
+// exportMoviesHandler streams all movies matching the same criteria as
+// listMoviesHandler as a JSON array, NDJSON or CSV. The format is taken from
+// the format query string parameter or else from the Accept header.
+func (app *application) exportMoviesHandler(writer http.ResponseWriter, request *http.Request) {
+	var input struct {
+		data.MovieCriteria
+		data.Filters
+		Format        string
+		RuntimeFormat data.RuntimeFormat
+	}
+
+	v := validator.New()
+	qs := request.URL.Query()
+
+	input.MovieCriteria = app.readMovieCriteria(qs, v)
+	input.Filters.Sort = app.readMovieSort(qs, input.MovieCriteria, v)
+	input.Filters.SortSafelist = movieSortSafelist
+	input.Format = app.readString(qs, "format", app.exportFormatFromAccept(request))
+	input.RuntimeFormat = app.readRuntimeFormat(qs, v)
+
+	data.ValidateMovieCriteria(v, input.MovieCriteria)
+	v.Check(validator.PermittedValue(input.Filters.Sort, input.Filters.SortSafelist...), "sort", "invalid sort value")
+	v.Check(exportContentTypes[input.Format] != "", "format", "must be one of json, ndjson or csv")
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	// an export may take much longer than the server's write timeout allows, so
+	// the deadline is pushed forward with every batch of movies instead
+	controller := http.NewResponseController(writer)
+	err := controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", exportContentTypes[input.Format])
+	writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movies.%s"`, input.Format))
+	writer.WriteHeader(http.StatusOK)
+
+	var write func(*data.Movie) error
+	var finish func() error
+
+	switch input.Format {
+	case "ndjson":
+		encoder := json.NewEncoder(writer)
+		write = func(movie *data.Movie) error {
+			return encoder.Encode(movie)
+		}
+		finish = func() error {
+			return nil
+		}
+	case "csv":
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Write([]string{"id", "title", "year", "runtime", "genres", "average_rating", "rating_count", "version"})
+		write = func(movie *data.Movie) error {
+			return csvWriter.Write([]string{
+				strconv.FormatInt(movie.ID, 10),
+				movie.Title,
+				strconv.FormatInt(int64(movie.Year), 10),
+				strconv.FormatInt(int64(movie.RuntimeMin), 10),
+				strings.Join(movie.Genres, ","),
+				strconv.FormatFloat(movie.AverageRating, 'f', -1, 64),
+				strconv.FormatInt(movie.RatingCount, 10),
+				strconv.FormatInt(int64(movie.Version), 10),
+			})
+		}
+		finish = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	default:
+		separator := "["
+		write = func(movie *data.Movie) error {
+			js, err := json.Marshal(movie)
+			if err != nil {
+				return err
+			}
+
+			_, err = writer.Write(append([]byte(separator), js...))
+			separator = ","
+			return err
+		}
+		finish = func() error {
+			if separator == "[" {
+				_, err := writer.Write([]byte("[]\n"))
+				return err
+			}
+
+			_, err := writer.Write([]byte("]\n"))
+			return err
+		}
+	}
+
+	count := 0
+
+	err = app.models.Movies.Export(request.Context(), input.MovieCriteria, input.Filters, func(movie *data.Movie) error {
+		count++
+		if count%exportBatchSize == 0 {
+			err := controller.Flush()
+			if err != nil {
+				return err
+			}
+
+			err = controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+			if err != nil {
+				return err
+			}
+		}
+
+		movie.RuntimeFormat = input.RuntimeFormat
+		return write(movie)
+	})
+	if err == nil {
+		err = finish()
+	}
+
+	// the status has been sent already, so all that is left is to log the error
+	if err != nil {
+		app.logError(request, err)
+	}
+}
+
+func (app *application) exportFormatFromAccept(request *http.Request) string {
+	for _, accepted := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		for format, contentType := range exportContentTypes {
+			if mediaType == contentType {
+				return format
+			}
+		}
+	}
+
+	return "json"
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,19 +10,25 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/mwettste/greenlight/internal/data"
 	"github.com/mwettste/greenlight/internal/validator"
 )
 
 type envelope map[string]interface{}
 
 func (app *application) readIDParameter(request *http.Request) (int64, error) {
+	return app.readNamedIDParameter(request, "id")
+}
+
+func (app *application) readNamedIDParameter(request *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(request.Context())
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
@@ -47,6 +54,81 @@ func (app *application) writeJSON(writer http.ResponseWriter, status int, data i
 	return nil
 }
 
+// formatMovies sets the runtime format requested by the client, as read by
+// readRuntimeFormat, on the movies, which have to be passed here before being
+// written.
+func (app *application) formatMovies(format data.RuntimeFormat, movies ...*data.Movie) {
+	for _, movie := range movies {
+		if movie != nil {
+			movie.RuntimeFormat = format
+		}
+	}
+}
+
+// formatRevisions works like formatMovies for the snapshots of movies.
+func (app *application) formatRevisions(format data.RuntimeFormat, revisions ...*data.MovieRevision) {
+	for _, revision := range revisions {
+		revision.RuntimeFormat = format
+	}
+}
+
+// writeJSONWithWeakETag works like writeJSON, but tags the response with a weak
+// ETag computed from its content and answers a matching If-None-Match header
+// with 304 Not Modified instead of sending the same content again.
+func (app *application) writeJSONWithWeakETag(writer http.ResponseWriter, request *http.Request, status int, data interface{}, headers http.Header) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(js)
+	etag := fmt.Sprintf(`W/"%x"`, hash[:16])
+
+	if etagMatches(request.Header.Get("If-None-Match"), etag, true) {
+		writer.Header().Set("ETag", etag)
+		writer.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set("ETag", etag)
+
+	return app.writeJSON(writer, status, data, headers)
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header value
+// contains the given entity tag. Weak comparison ignores the W/ prefix, as
+// required for If-None-Match, whereas If-Match needs strong comparison.
+func etagMatches(header, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+
+	if header == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (app *application) readJSON(writer http.ResponseWriter, request *http.Request, dst interface{}) error {
 	maxBytes := 1_048_576
 	request.Body = http.MaxBytesReader(writer, request.Body, int64(maxBytes))
@@ -119,6 +201,44 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// readRuntimeFormat reads the runtime_format query string parameter of the
+// handlers which return movies or revisions, see formatMovies.
+func (app *application) readRuntimeFormat(qs url.Values, v *validator.Validator) data.RuntimeFormat {
+	format := data.RuntimeFormat(qs.Get("runtime_format"))
+	v.Check(format == data.RuntimeFormatDefault || validator.PermittedValue(format, data.RuntimeFormats...), "runtime_format", "must be one of iso8601, minutes or human")
+	return format
+}
+
+func (app *application) readDate(qs url.Values, key string, v *validator.Validator) data.Date {
+	s := qs.Get(key)
+	if s == "" {
+		return data.Date{}
+	}
+
+	date, err := data.ParseDate(s)
+	if err != nil {
+		v.AddError(key, "must be a date in the format YYYY-MM-DD")
+		return data.Date{}
+	}
+
+	return date
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 	go func() {
@@ -132,3 +252,31 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// periodically runs fn right away and then once per interval as a background
+// task, until the server shuts down. A panic in fn is logged and doesn't stop
+// later runs.
+func (app *application) periodically(interval time.Duration, fn func()) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			func() {
+				defer func() {
+					if err := recover(); err != nil {
+						app.logger.PrintError(fmt.Errorf("%s", err), nil)
+					}
+				}()
+
+				fn()
+			}()
+
+			select {
+			case <-ticker.C:
+			case <-app.shutdown:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mwettste/greenlight/internal/data"
+)
+
+// idempotentHeaders are the response headers which are stored along with the
+// response body and replayed to retries.
+var idempotentHeaders = []string{"Content-Type", "Content-Language", "ETag", "Location"}
+
+// idempotencyLease is how long a key stays reserved for a request which is
+// still being processed. Should the server die before the request completes,
+// the key can be claimed again once the lease has expired.
+const idempotencyLease = time.Minute
+
+// idempotencyRecorder passes a response through to the client while keeping a
+// copy of its status and body.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *idempotencyRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotent lets clients safely retry a request by sending an Idempotency-Key
+// header. The response to the first request with a key is stored per user and
+// replayed to every retry with the same key and body until the configured ttl
+// expires. Requests without the header are passed through unchanged.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(writer, request)
+			return
+		}
+
+		if len(key) > 255 {
+			app.badRequestResponse(writer, request, errors.New("Idempotency-Key header must not be more than 255 bytes long"))
+			return
+		}
+
+		maxBytes := 1_048_576
+		body, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, int64(maxBytes)))
+		if err != nil {
+			app.badRequestResponse(writer, request, fmt.Errorf("body must not be larger than %d bytes", maxBytes))
+			return
+		}
+
+		request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// a key must not be reused for another endpoint either
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", request.Method, request.URL.RequestURI())
+		hash.Write(body)
+		fingerprint := hash.Sum(nil)
+
+		// anonymous clients can't be told apart, so their keys are stored along
+		// with the fingerprint: a key only ever replays the response to the very
+		// same request, and other clients' requests with that key don't collide
+		user := app.contextGetUser(request)
+		userID := user.ID
+
+		if user.IsAnonymous() {
+			key = fmt.Sprintf("%s:%x", key, fingerprint)
+		}
+
+		reservedUntil, existing, err := app.models.Idempotency.Reserve(userID, key, fingerprint, idempotencyLease)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrIdempotencyKeyInUse):
+				app.idempotencyKeyInUseResponse(writer, request)
+			default:
+				app.serverErrorResponse(writer, request, err)
+			}
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case !bytes.Equal(existing.Fingerprint, fingerprint):
+				app.idempotencyKeyMismatchResponse(writer, request)
+			case existing.Status == 0:
+				app.idempotencyKeyInUseResponse(writer, request)
+			default:
+				for name, values := range existing.Header {
+					writer.Header()[name] = values
+				}
+				writer.Header().Set("Idempotent-Replayed", "true")
+				writer.WriteHeader(existing.Status)
+				writer.Write(existing.Body)
+			}
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: writer}
+		completed := false
+
+		// the key is released if the request failed or panicked, so that it can be retried
+		defer func() {
+			if completed {
+				return
+			}
+
+			err := app.models.Idempotency.Release(userID, key, reservedUntil)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		}()
+
+		next(recorder, request)
+
+		if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		stored := &data.IdempotencyKey{
+			UserID: userID,
+			Key:    key,
+			Status: recorder.status,
+			Header: make(http.Header),
+			Body:   recorder.body.Bytes(),
+			Expiry: time.Now().Add(app.config.idempotency.ttl),
+		}
+
+		for _, name := range idempotentHeaders {
+			if values := writer.Header().Values(name); len(values) > 0 {
+				stored.Header[name] = values
+			}
+		}
+
+		err = app.models.Idempotency.Complete(stored, reservedUntil)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+
+		completed = true
+	}
+}
+
+// purgeExpiredIdempotencyKeys hourly deletes idempotency keys whose ttl has
+// expired.
+func (app *application) purgeExpiredIdempotencyKeys() {
+	app.periodically(time.Hour, func() {
+		count, err := app.models.Idempotency.DeleteExpired()
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+
+		if count > 0 {
+			app.logger.PrintInfo("deleted expired idempotency keys", map[string]string{
+				"count": strconv.FormatInt(count, 10),
+			})
+		}
+	})
+}
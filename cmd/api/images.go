@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/storage"
+	"github.com/mwettste/greenlight/internal/validator"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	maxImageBytes     = 10 * 1_048_576
+	maxImageDimension = 8000
+
+	// maxImagePixels bounds the memory needed to decode an image, which takes
+	// 4 bytes per pixel, to 64MB
+	maxImagePixels = 4096 * 4096
+
+	// imageReadTimeout allows the largest image to be uploaded at 64KB/s
+	imageReadTimeout = (maxImageBytes + 1_048_576) / (64 * 1024) * time.Second
+)
+
+// imageSpec describes the minimum size of an image kind and the widths of its
+// variants, in the order of data.ImageVariants.
+type imageSpec struct {
+	minWidth  int
+	minHeight int
+	widths    []int
+}
+
+var imageSpecs = map[string]imageSpec{
+	data.ImagePoster:   {minWidth: 300, minHeight: 450, widths: []int{185, 342, 780}},
+	data.ImageBackdrop: {minWidth: 1280, minHeight: 720, widths: []int{300, 780, 1280}},
+}
+
+// uploadMovieImageHandler replaces the movie's image of the given kind with a
+// JPEG, PNG or WebP image uploaded in the image field of a multipart form. The
+// image is stored as resized JPEG variants under a new token.
+func (app *application) uploadMovieImageHandler(kind string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		id, err := app.readIDParameter(request)
+		if err != nil {
+			app.notFoundResponse(writer, request)
+			return
+		}
+
+		movie, err := app.models.Movies.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(writer, request)
+			default:
+				app.serverErrorResponse(writer, request, err)
+			}
+			return
+		}
+
+		// a large image may take much longer to upload than the server's read timeout allows
+		err = http.NewResponseController(writer).SetReadDeadline(time.Now().Add(imageReadTimeout))
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		// leave some room for the rest of the multipart form
+		request.Body = http.MaxBytesReader(writer, request.Body, maxImageBytes+1_048_576)
+
+		err = request.ParseMultipartForm(1_048_576)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.Is(err, http.ErrNotMultipart):
+				app.unsupportedMediaTypeResponse(writer, request, "multipart/form-data")
+			case errors.As(err, &maxBytesError):
+				app.badRequestResponse(writer, request, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+			default:
+				app.badRequestResponse(writer, request, fmt.Errorf("body contains a badly formed multipart form: %v", err))
+			}
+			return
+		}
+		defer request.MultipartForm.RemoveAll()
+
+		v := validator.New()
+
+		runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
+
+		file, _, err := request.FormFile("image")
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				v.AddError("image", "must be provided")
+				app.failedValidationResponse(writer, request, v.Errors)
+				return
+			}
+
+			app.badRequestResponse(writer, request, err)
+			return
+		}
+		defer file.Close()
+
+		content, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		contentType := http.DetectContentType(content)
+		v.Check(len(content) <= maxImageBytes, "image", fmt.Sprintf("must not be larger than %d bytes", maxImageBytes))
+		v.Check(validator.PermittedValue(contentType, "image/jpeg", "image/png", "image/webp"), "image", "must be a JPEG, PNG or WebP image")
+
+		if !v.Valid() {
+			app.failedValidationResponse(writer, request, v.Errors)
+			return
+		}
+
+		// the dimensions are checked before decoding, which could otherwise
+		// allocate huge amounts of memory
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			v.AddError("image", "could not be decoded")
+			app.failedValidationResponse(writer, request, v.Errors)
+			return
+		}
+
+		spec := imageSpecs[kind]
+		v.Check(config.Width >= spec.minWidth && config.Height >= spec.minHeight, "image", fmt.Sprintf("must be at least %dx%d pixels", spec.minWidth, spec.minHeight))
+		v.Check(config.Width <= maxImageDimension && config.Height <= maxImageDimension, "image", fmt.Sprintf("must not be larger than %dx%d pixels", maxImageDimension, maxImageDimension))
+		v.Check(config.Width*config.Height <= maxImagePixels, "image", fmt.Sprintf("must not have more than %d pixels", maxImagePixels))
+
+		if !v.Valid() {
+			app.failedValidationResponse(writer, request, v.Errors)
+			return
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(content))
+		if err != nil {
+			v.AddError("image", "could not be decoded")
+			app.failedValidationResponse(writer, request, v.Errors)
+			return
+		}
+
+		token, err := generateImageToken()
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		movieImage := &data.MovieImage{
+			MovieID: movie.ID,
+			Kind:    kind,
+			Token:   token,
+			Width:   config.Width,
+			Height:  config.Height,
+		}
+
+		for i, variant := range data.ImageVariants {
+			var buf bytes.Buffer
+
+			err = jpeg.Encode(&buf, resizeImage(img, spec.widths[i]), &jpeg.Options{Quality: 85})
+			if err == nil {
+				err = app.storage.Put(imageKey(movieImage.Image(), variant), &buf)
+			}
+
+			if err != nil {
+				app.deleteImageFiles(movieImage.Image())
+				app.serverErrorResponse(writer, request, err)
+				return
+			}
+		}
+
+		previous, err := app.models.Images.Upsert(movieImage)
+		if err != nil {
+			app.deleteImageFiles(movieImage.Image())
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		if previous != "" {
+			app.deleteImageFiles(previous)
+		}
+
+		switch kind {
+		case data.ImagePoster:
+			movie.Poster = movieImage.Image()
+		case data.ImageBackdrop:
+			movie.Backdrop = movieImage.Image()
+		}
+
+		headers := make(http.Header)
+		headers.Set("ETag", movieETag(movie))
+
+		app.formatMovies(runtimeFormat, movie)
+
+		err = app.writeJSON(writer, http.StatusOK, envelope{"movie": movie}, headers)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+		}
+	}
+}
+
+func (app *application) deleteMovieImageHandler(kind string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		id, err := app.readIDParameter(request)
+		if err != nil {
+			app.notFoundResponse(writer, request)
+			return
+		}
+
+		_, err = app.models.Movies.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(writer, request)
+			default:
+				app.serverErrorResponse(writer, request, err)
+			}
+			return
+		}
+
+		image, err := app.models.Images.Delete(id, kind)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(writer, request)
+			default:
+				app.serverErrorResponse(writer, request, err)
+			}
+			return
+		}
+
+		app.deleteImageFiles(image)
+
+		err = app.writeJSON(writer, http.StatusOK, envelope{"message": fmt.Sprintf("%s successfully deleted", kind)}, nil)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+		}
+	}
+}
+
+// showImageHandler serves a variant of an uploaded image. A new token is
+// generated for every upload, so the variants never change and may be cached
+// indefinitely.
+func (app *application) showImageHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readNamedIDParameter(request, "movie_id")
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	params := httprouter.ParamsFromContext(request.Context())
+	token := params.ByName("token")
+	variant := params.ByName("variant")
+
+	if !isImageToken(token) || !validator.PermittedValue(variant, data.ImageVariants...) {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	image := data.Image(fmt.Sprintf("%d/%s", movieID, token))
+
+	file, modTime, err := app.storage.Open(imageKey(image, variant))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	writer.Header().Set("Content-Type", "image/jpeg")
+	writer.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	writer.Header().Set("ETag", fmt.Sprintf(`"%s-%s"`, token, variant))
+
+	http.ServeContent(writer, request, "", modTime, file)
+}
+
+// deleteImageFiles deletes all variants of the image. Failures are only logged
+// as the image is no longer referenced anyway.
+func (app *application) deleteImageFiles(image data.Image) {
+	err := app.storage.DeleteAll(string(image) + "/")
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"image": string(image)})
+	}
+}
+
+// deleteMovieImageFiles deletes the files of all images of a purged movie.
+func (app *application) deleteMovieImageFiles(movieID int64) {
+	err := app.storage.DeleteAll(fmt.Sprintf("%d/", movieID))
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"movie_id": fmt.Sprint(movieID)})
+	}
+}
+
+func imageKey(image data.Image, variant string) string {
+	return fmt.Sprintf("%s/%s.jpg", image, variant)
+}
+
+func generateImageToken() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+func isImageToken(token string) bool {
+	decoded, err := hex.DecodeString(token)
+	return err == nil && len(decoded) == 16
+}
+
+// resizeImage scales the image down to the given width, keeping its aspect
+// ratio. Transparent areas are filled with white as JPEG has no alpha channel.
+func resizeImage(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(resized, resized.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+
+	return resized
+}
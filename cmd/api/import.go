@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+const (
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+
+	maxImportBytes = 10 * 1_048_576
+	maxImportRows  = 10_000
+
+	// importReadTimeout allows the largest import to be uploaded at 64KB/s
+	importReadTimeout = maxImportBytes / (64 * 1024) * time.Second
+)
+
+// importRow is a single movie read from an upload, together with the line it
+// was found on and whatever made it invalid.
+type importRow struct {
+	line   int
+	movie  *data.Movie
+	errors map[string]string
+}
+
+type importResult struct {
+	Line   int               `json:"line"`
+	Status string            `json:"status"`
+	ID     int64             `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// importMoviesHandler creates movies from a CSV (with a title,year,runtime,genres
+// header and comma separated genres) or NDJSON upload. In atomic mode nothing is
+// created unless every row is valid, in best_effort mode all valid rows are created.
+// Rows which are probably duplicates of an existing movie, as detected when
+// creating a movie, are skipped in both modes.
+func (app *application) importMoviesHandler(writer http.ResponseWriter, request *http.Request) {
+	v := validator.New()
+	qs := request.URL.Query()
+
+	mode := app.readString(qs, "mode", importModeAtomic)
+	v.Check(validator.PermittedValue(mode, importModeAtomic, importModeBestEffort), "mode", "must be either atomic or best_effort")
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	// a large import may take much longer to upload and process than the
+	// server's read and write timeouts allow
+	controller := http.NewResponseController(writer)
+
+	err := controller.SetReadDeadline(time.Now().Add(importReadTimeout))
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = controller.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	// imports are expected to be much larger than the bodies readJSON accepts
+	request.Body = http.MaxBytesReader(writer, request.Body, maxImportBytes)
+
+	var rows []*importRow
+
+	contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch contentType {
+	case "text/csv":
+		rows, err = app.readImportCSV(request.Body)
+	case "application/x-ndjson":
+		rows, err = app.readImportNDJSON(request.Body)
+	default:
+		app.unsupportedMediaTypeResponse(writer, request, "text/csv", "application/x-ndjson")
+		return
+	}
+
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	genres, err := app.models.Genres.GetAllSlugs()
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	user := app.contextGetUser(request)
+	results := make([]*importResult, len(rows))
+	valid := []*importRow{}
+
+	for i, row := range rows {
+		results[i] = &importResult{Line: row.line}
+
+		if row.errors == nil {
+			rowValidator := validator.New()
+			data.ValidateMovie(rowValidator, row.movie, genres)
+			row.errors = rowValidator.Errors
+		}
+
+		if len(row.errors) > 0 {
+			results[i].Status = "failed"
+			results[i].Errors = row.errors
+			continue
+		}
+
+		valid = append(valid, row)
+	}
+
+	failed := len(valid) < len(rows)
+
+	switch {
+	case mode == importModeAtomic && failed:
+		// nothing is imported, so the valid rows are merely skipped
+		for _, result := range results {
+			if result.Status == "" {
+				result.Status = "skipped"
+			}
+		}
+	case mode == importModeAtomic:
+		movies := make([]*data.Movie, len(valid))
+		for i, row := range valid {
+			movies[i] = row.movie
+		}
+
+		created, err := app.models.Movies.InsertBatch(movies, user.ID)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		for i, result := range results {
+			result.Status = "skipped"
+			if created[i] {
+				result.Status = "created"
+				result.ID = movies[i].ID
+			}
+		}
+	default:
+		movies := make([]*data.Movie, len(valid))
+		for i, row := range valid {
+			movies[i] = row.movie
+		}
+
+		created, errs, err := app.models.Movies.InsertEach(movies, user.ID)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		i := 0
+		for _, result := range results {
+			if result.Status != "" {
+				continue
+			}
+
+			switch {
+			case errs[i] != nil:
+				app.logError(request, errs[i])
+				result.Status = "failed"
+				result.Errors = map[string]string{"movie": "could not be saved"}
+			case created[i]:
+				result.Status = "created"
+				result.ID = movies[i].ID
+			default:
+				result.Status = "skipped"
+			}
+
+			i++
+		}
+	}
+
+	summary := map[string]int{"created": 0, "skipped": 0, "failed": 0}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+
+	status := http.StatusOK
+	if mode == importModeAtomic && failed {
+		status = http.StatusUnprocessableEntity
+	}
+
+	err = app.writeJSON(writer, status, envelope{"summary": summary, "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) readImportCSV(body io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, fmt.Errorf("body contains badly formed CSV: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header must contain a %q column", name)
+		}
+	}
+
+	rows := []*importRow{}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("body contains badly formed CSV: %v", err)
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("body must not contain more than %d rows", maxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := &importRow{line: line, movie: &data.Movie{}}
+		rows = append(rows, row)
+
+		if len(record) != len(header) {
+			row.errors = map[string]string{"row": fmt.Sprintf("must contain %d fields", len(header))}
+			continue
+		}
+
+		v := validator.New()
+		row.movie.Title = record[columns["title"]]
+
+		year, err := strconv.ParseInt(record[columns["year"]], 10, 32)
+		v.Check(err == nil, "year", "must be an integer value")
+		row.movie.Year = int32(year)
+
+		runtime, err := data.ParseRuntime(strings.TrimSpace(record[columns["runtime"]]))
+		v.Check(err == nil, "runtime", "must be a number of minutes, in the format \"<n> mins\", \"1h 47m\" or an ISO 8601 duration")
+		row.movie.RuntimeMin = runtime
+
+		genres := []string{}
+		for _, genre := range strings.Split(record[columns["genres"]], ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				genres = append(genres, genre)
+			}
+		}
+		row.movie.Genres = genres
+
+		if !v.Valid() {
+			row.errors = v.Errors
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must contain at least one row")
+	}
+
+	return rows, nil
+}
+
+func (app *application) readImportNDJSON(body io.Reader) ([]*importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1_048_576)
+
+	rows := []*importRow{}
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("body must not contain more than %d rows", maxImportRows)
+		}
+
+		var input struct {
+			Title   string          `json:"title"`
+			Year    int32           `json:"year"`
+			Runtime data.RuntimeMin `json:"runtime"`
+			Genres  []string        `json:"genres"`
+		}
+
+		row := &importRow{line: line}
+		rows = append(rows, row)
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+
+		err := decoder.Decode(&input)
+		if err == nil && decoder.More() {
+			err = errors.New("line must only contain a single JSON value")
+		}
+
+		if err != nil {
+			row.errors = map[string]string{"row": err.Error()}
+			continue
+		}
+
+		row.movie = &data.Movie{
+			Title:      input.Title,
+			Year:       input.Year,
+			RuntimeMin: input.Runtime,
+			Genres:     input.Genres,
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("body could not be read: %v", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+
+	return rows, nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/mwettste/greenlight/internal/data"
 	"github.com/mwettste/greenlight/internal/jsonlog"
 	"github.com/mwettste/greenlight/internal/mailer"
+	"github.com/mwettste/greenlight/internal/storage"
 )
 
 var (
@@ -47,14 +48,28 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	trash struct {
+		retention time.Duration
+	}
+	requireIfMatch bool
+	storage        struct {
+		dir string
+	}
+	idempotency struct {
+		ttl time.Duration
+	}
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *jsonlog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	storage storage.Storage
+	wg      sync.WaitGroup
+
+	// shutdown is closed when the server begins to shut down, stopping periodic tasks
+	shutdown chan struct{}
 }
 
 func main() {
@@ -83,6 +98,14 @@ func main() {
 		return nil
 	})
 
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "Time after which deleted movies are purged (0 disables purging)")
+
+	flag.BoolVar(&cfg.requireIfMatch, "require-if-match", false, "Reject movie updates and deletes without an If-Match header")
+
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory to store uploaded images in")
+
+	flag.DurationVar(&cfg.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "Time for which responses to requests with an Idempotency-Key are replayed")
+
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
@@ -118,12 +141,17 @@ func main() {
 	}))
 
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:   cfg,
+		logger:   logger,
+		models:   data.NewModels(db),
+		mailer:   mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		storage:  storage.NewLocal(cfg.storage.dir),
+		shutdown: make(chan struct{}),
 	}
 
+	app.purgeExpiredTrash()
+	app.purgeExpiredIdempotencyKeys()
+
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
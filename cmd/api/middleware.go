@@ -185,10 +185,11 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.config.cors.trustedOrigins {
 				if origin == app.config.cors.trustedOrigins[i] {
 					writer.Header().Set("Access-Control-Allow-Origin", origin)
+					writer.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed")
 
 					if request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != "" {
 						writer.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, If-Match, If-None-Match")
 
 						writer.WriteHeader(http.StatusOK)
 						return
@@ -1,20 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/jsonpatch"
 	"github.com/mwettste/greenlight/internal/validator"
 )
 
 func (app *application) createMovieHandler(writer http.ResponseWriter, request *http.Request) {
 	var input struct {
-		Title   string          `json:"title"`
-		Year    int32           `json:"year"`
-		Runtime data.RuntimeMin `json:"runtime"`
-		Genres  []string        `json:"genres"`
+		Title       string           `json:"title"`
+		Year        int32            `json:"year"`
+		Runtime     data.RuntimeMin  `json:"runtime"`
+		Genres      []string         `json:"genres"`
+		ExternalIDs data.ExternalIDs `json:"external_ids"`
 	}
 
 	err := app.readJSON(writer, request, &input)
@@ -24,27 +32,60 @@ func (app *application) createMovieHandler(writer http.ResponseWriter, request *
 	}
 
 	movie := &data.Movie{
-		Title:      input.Title,
-		Year:       input.Year,
-		RuntimeMin: input.Runtime,
-		Genres:     input.Genres,
+		Title:       input.Title,
+		Year:        input.Year,
+		RuntimeMin:  input.Runtime,
+		Genres:      input.Genres,
+		ExternalIDs: input.ExternalIDs,
+	}
+
+	genres, err := app.models.Genres.GetAllSlugs()
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
 	}
 
 	v := validator.New()
+	qs := request.URL.Query()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	allowDuplicate := app.readBool(qs, "allow_duplicate", false, v)
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(writer, request, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Insert(movie)
+	if !allowDuplicate {
+		duplicates, err := app.models.Movies.FindDuplicates(movie)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+
+		if len(duplicates) > 0 {
+			app.duplicateMovieResponse(writer, request, duplicates)
+			return
+		}
+	}
+
+	err = app.models.Movies.Insert(movie, app.contextGetUser(request).ID)
 	if err != nil {
-		app.serverErrorResponse(writer, request, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "must not be used by another movie")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
 		return
 	}
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("ETag", movieETag(movie))
+
+	app.formatMovies(runtimeFormat, movie)
 
 	err = app.writeJSON(writer, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
@@ -59,6 +100,28 @@ func (app *application) showMovieHandler(writer http.ResponseWriter, request *ht
 		return
 	}
 
+	v := validator.New()
+	qs := request.URL.Query()
+
+	include := app.readCSV(qs, "include", []string{})
+	for _, value := range include {
+		v.Check(validator.PermittedValue(value, "credits"), "include", "invalid include value")
+	}
+
+	locales := app.readLocales(request, v)
+
+	country := app.readString(qs, "country", "")
+	if country != "" {
+		data.ValidateCountry(v, "country", country)
+	}
+
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
 	movie, err := app.models.Movies.Get(id)
 	if err != nil {
 		switch {
@@ -70,6 +133,52 @@ func (app *application) showMovieHandler(writer http.ResponseWriter, request *ht
 		return
 	}
 
+	err = app.localizeMovies(writer, []*data.Movie{movie}, locales)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	if validator.PermittedValue("credits", include...) {
+		movie.Credits, err = app.models.Credits.GetAllForMovie(movie.ID)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+	}
+
+	movie.Collections, err = app.models.Collections.GetAllForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	if country != "" {
+		err = app.models.Releases.Certify([]*data.Movie{movie}, country)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+	}
+
+	app.formatMovies(runtimeFormat, movie)
+
+	// credits, collections, releases, images, ratings and translations change without
+	// changing the movie's version, so the ETag covers the whole representation
+	js, err := json.Marshal(envelope{"movie": movie})
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	etag := movieRepresentationETag(movie, js)
+	writer.Header().Set("ETag", etag)
+
+	if etagMatches(request.Header.Get("If-None-Match"), etag, true) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	err = app.writeJSON(writer, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(writer, request, err)
@@ -94,47 +203,84 @@ func (app *application) updateMovieHandler(writer http.ResponseWriter, request *
 		return
 	}
 
-	var input struct {
-		Title   *string          `json:"title"`
-		Year    *int32           `json:"year"`
-		Runtime *data.RuntimeMin `json:"runtime"`
-		Genres  []string         `json:"genres"`
-	}
-
-	err = app.readJSON(writer, request, &input)
-	if err != nil {
-		app.badRequestResponse(writer, request, err)
+	if !app.checkIfMatch(writer, request, movieETag(movie)) {
 		return
 	}
 
-	if input.Title != nil {
-		movie.Title = *input.Title
-	}
+	contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch contentType {
+	case "application/merge-patch+json", "application/json-patch+json":
+		if !app.applyMoviePatch(writer, request, contentType, movie) {
+			return
+		}
+	default:
+		var input struct {
+			Title       *string            `json:"title"`
+			Year        *int32             `json:"year"`
+			Runtime     *data.RuntimeMin   `json:"runtime"`
+			Genres      []string           `json:"genres"`
+			ExternalIDs map[string]*string `json:"external_ids"`
+		}
 
-	if input.Year != nil {
-		movie.Year = *input.Year
-	}
+		err = app.readJSON(writer, request, &input)
+		if err != nil {
+			app.badRequestResponse(writer, request, err)
+			return
+		}
+
+		if input.Title != nil {
+			movie.Title = *input.Title
+		}
+
+		if input.Year != nil {
+			movie.Year = *input.Year
+		}
+
+		if input.Genres != nil {
+			movie.Genres = input.Genres
+		}
 
-	if input.Genres != nil {
-		movie.Genres = input.Genres
+		if input.Runtime != nil {
+			movie.RuntimeMin = *input.Runtime
+		}
+
+		// external IDs are changed individually, null removes an ID
+		if input.ExternalIDs != nil && movie.ExternalIDs == nil {
+			movie.ExternalIDs = data.ExternalIDs{}
+		}
+
+		for source, id := range input.ExternalIDs {
+			if id == nil {
+				delete(movie.ExternalIDs, source)
+				continue
+			}
+
+			movie.ExternalIDs[source] = *id
+		}
 	}
 
-	if input.Runtime != nil {
-		movie.RuntimeMin = *input.Runtime
+	genres, err := app.models.Genres.GetAllSlugs()
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
 	}
 
 	v := validator.New()
+	runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(writer, request, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(request).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(writer, request)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "must not be used by another movie")
+			app.failedValidationResponse(writer, request, v.Errors)
 		default:
 			app.serverErrorResponse(writer, request, err)
 		}
@@ -142,10 +288,119 @@ func (app *application) updateMovieHandler(writer http.ResponseWriter, request *
 		return
 	}
 
-	err = app.writeJSON(writer, http.StatusOK, envelope{"movie": movie}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	app.formatMovies(runtimeFormat, movie)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+// moviePatchDocument holds the fields of a movie which merge patches and JSON
+// patches are applied to.
+type moviePatchDocument struct {
+	Title       string           `json:"title"`
+	Year        int32            `json:"year"`
+	Runtime     data.RuntimeMin  `json:"runtime"`
+	Genres      []string         `json:"genres"`
+	ExternalIDs data.ExternalIDs `json:"external_ids"`
+}
+
+// applyMoviePatch applies a JSON Merge Patch (RFC 7386) or a JSON Patch
+// (RFC 6902) to the movie. Fields removed by the patch are cleared, which
+// leaves it to data.ValidateMovie to reject the result. It sends an error
+// response and returns false if the patch can't be applied.
+func (app *application) applyMoviePatch(writer http.ResponseWriter, request *http.Request, contentType string, movie *data.Movie) bool {
+	externalIDs := movie.ExternalIDs
+	if externalIDs == nil {
+		// allows adding IDs with JSON patches such as {"op": "add", "path": "/external_ids/imdb", ...}
+		externalIDs = data.ExternalIDs{}
+	}
+
+	js, err := json.Marshal(moviePatchDocument{
+		Title:       movie.Title,
+		Year:        movie.Year,
+		Runtime:     movie.RuntimeMin,
+		Genres:      movie.Genres,
+		ExternalIDs: externalIDs,
+	})
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return false
+	}
+
+	var doc interface{}
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return false
+	}
+
+	if contentType == "application/merge-patch+json" {
+		var patch interface{}
+
+		err = app.readJSON(writer, request, &patch)
+		if err != nil {
+			app.badRequestResponse(writer, request, err)
+			return false
+		}
+
+		doc = jsonpatch.MergePatch(doc, patch)
+	} else {
+		var patch []jsonpatch.Operation
+
+		err = app.readJSON(writer, request, &patch)
+		if err != nil {
+			app.badRequestResponse(writer, request, err)
+			return false
+		}
+
+		doc, err = jsonpatch.Apply(doc, patch)
+		if err != nil {
+			switch {
+			case errors.Is(err, jsonpatch.ErrTestFailed):
+				app.errorResponse(writer, request, http.StatusConflict, err.Error())
+			default:
+				app.failedValidationResponse(writer, request, map[string]string{"patch": err.Error()})
+			}
+			return false
+		}
+	}
+
+	js, err = json.Marshal(doc)
 	if err != nil {
 		app.serverErrorResponse(writer, request, err)
+		return false
+	}
+
+	var patched moviePatchDocument
+
+	decoder := json.NewDecoder(bytes.NewReader(js))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&patched)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+
+		switch {
+		case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+			app.failedValidationResponse(writer, request, map[string]string{unmarshalTypeError.Field: "has an incorrect JSON type"})
+		default:
+			app.failedValidationResponse(writer, request, map[string]string{"patch": fmt.Sprintf("does not result in a valid movie: %v", err)})
+		}
+		return false
 	}
+
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.RuntimeMin = patched.Runtime
+	movie.Genres = patched.Genres
+	movie.ExternalIDs = patched.ExternalIDs
+
+	return true
 }
 
 func (app *application) deleteMoveHandler(writer http.ResponseWriter, request *http.Request) {
@@ -155,7 +410,22 @@ func (app *application) deleteMoveHandler(writer http.ResponseWriter, request *h
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	if !app.checkIfMatch(writer, request, movieETag(movie)) {
+		return
+	}
+
+	err = app.models.Movies.Delete(movie.ID, app.contextGetUser(request).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -173,35 +443,382 @@ func (app *application) deleteMoveHandler(writer http.ResponseWriter, request *h
 	}
 }
 
+// movieETag identifies a version of a movie, as every change increments its
+// version. It is what If-Match headers are checked against, so that e.g. a
+// review written by someone else doesn't make an edit fail.
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d-%d"`, movie.ID, movie.Version)
+}
+
+// movieRepresentationETag extends movieETag with a hash of the response body,
+// as the images, ratings, translations and runtime format of a movie change
+// its representation without changing its version. It answers If-None-Match
+// headers, whereas If-Match headers only need to match the version part.
+func movieRepresentationETag(movie *data.Movie, body []byte) string {
+	hash := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%d-%x"`, movie.ID, movie.Version, hash[:8])
+}
+
+// checkIfMatch makes sure the client modifies the version of the resource it
+// has seen last. It sends an error response and returns false if the If-Match
+// header matches neither the current ETag nor a representation ETag derived
+// from it, or is missing although it's required.
+func (app *application) checkIfMatch(writer http.ResponseWriter, request *http.Request, etag string) bool {
+	ifMatch := request.Header.Get("If-Match")
+
+	if ifMatch == "" {
+		if app.config.requireIfMatch {
+			app.preconditionRequiredResponse(writer, request)
+			return false
+		}
+
+		return true
+	}
+
+	if !etagMatches(ifMatch, etag, false) && !etagExtends(ifMatch, etag) {
+		app.preconditionFailedResponse(writer, request)
+		return false
+	}
+
+	return true
+}
+
+// etagExtends reports whether the If-Match header value contains a strong
+// entity tag which extends the given one, the way movieRepresentationETag
+// extends movieETag.
+func etagExtends(header, etag string) bool {
+	prefix := strings.TrimSuffix(etag, `"`) + "-"
+
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.HasPrefix(strings.TrimSpace(candidate), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var movieSortSafelist = []string{"id", "title", "year", "runtime", "rating", "relevance", "-id", "-title", "-year", "-runtime", "-rating", "-relevance"}
+
+// readMovieCriteria reads the query string parameters which narrow down movie
+// listings. The criteria are validated by data.ValidateMovieCriteria.
+func (app *application) readMovieCriteria(qs url.Values, v *validator.Validator) data.MovieCriteria {
+	return data.MovieCriteria{
+		Title:            app.readString(qs, "title", ""),
+		SearchConfig:     app.readString(qs, "search_config", "simple"),
+		Genres:           app.readCSV(qs, "genres", []string{}),
+		GenresAny:        app.readCSV(qs, "genres_any", []string{}),
+		GenresExclude:    app.readCSV(qs, "genres_exclude", []string{}),
+		YearMin:          app.readInt(qs, "year_min", 0, v),
+		YearMax:          app.readInt(qs, "year_max", 0, v),
+		RuntimeMin:       app.readInt(qs, "runtime_min", 0, v),
+		RuntimeMax:       app.readInt(qs, "runtime_max", 0, v),
+		RelatedTo:        int64(app.readInt(qs, "related_to", 0, v)),
+		Relation:         app.readString(qs, "relation", ""),
+		Country:          app.readString(qs, "country", ""),
+		ReleasedAfter:    app.readDate(qs, "released_after", v),
+		MaxCertification: app.readString(qs, "max_certification", ""),
+	}
+}
+
+func (app *application) readMovieSort(qs url.Values, criteria data.MovieCriteria, v *validator.Validator) string {
+	sort := app.readString(qs, "sort", "id")
+
+	v.Check(strings.TrimPrefix(sort, "-") != "relevance" || criteria.Title != "", "sort", "relevance requires a title search")
+	return sort
+}
+
 func (app *application) listMoviesHandler(writer http.ResponseWriter, request *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieCriteria
 		data.Filters
 	}
 
 	v := validator.New()
 	qs := request.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.MovieCriteria = app.readMovieCriteria(qs, v)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.Sort = app.readMovieSort(qs, input.MovieCriteria, v)
+	input.Filters.SortSafelist = movieSortSafelist
+	input.Filters.CursorMode = qs.Has("cursor")
+	input.Filters.Cursor = qs.Get("cursor")
+	facets := app.readCSV(qs, "facets", []string{})
+	locales := app.readLocales(request, v)
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	for _, facet := range facets {
+		v.Check(validator.PermittedValue(facet, data.MovieFacets...), "facets", "must only contain genres, decade or runtime")
+	}
 
+	data.ValidateMovieCriteria(v, input.MovieCriteria)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(writer, request, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieCriteria, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.localizeMovies(writer, movies, locales)
 	if err != nil {
 		app.serverErrorResponse(writer, request, err)
 		return
 	}
 
-	err = app.writeJSON(writer, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	// certifications differ between countries, so they are only shown for the requested one
+	if input.MovieCriteria.Country != "" {
+		err = app.models.Releases.Certify(movies, input.MovieCriteria.Country)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+	}
+
+	app.formatMovies(runtimeFormat, movies...)
+
+	env := envelope{"movies": movies, "metadata": metadata}
+
+	// facets are opt-in, as they need to aggregate over all matching movies
+	if len(facets) > 0 {
+		env["facets"], err = app.models.Movies.GetFacets(input.MovieCriteria, facets)
+		if err != nil {
+			app.serverErrorResponse(writer, request, err)
+			return
+		}
+	}
+
+	err = app.writeJSONWithWeakETag(writer, request, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) autocompleteMoviesHandler(writer http.ResponseWriter, request *http.Request) {
+	v := validator.New()
+	qs := request.URL.Query()
+
+	q := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(q != "", "q", "must be provided")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Autocomplete(q, limit)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) listSimilarMoviesHandler(writer http.ResponseWriter, request *http.Request) {
+	id, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	v := validator.New()
+	qs := request.URL.Query()
+
+	limit := app.readInt(qs, "limit", 10, v)
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	movies, err := app.models.Movies.GetSimilar(movie, limit)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	app.formatMovies(runtimeFormat, movies...)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"movies": movies}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+// lookupMovieHandler finds a movie by its ID in one of the external datasets,
+// which is given as a query string parameter named after the dataset, e.g.
+// ?imdb=tt0120737.
+func (app *application) lookupMovieHandler(writer http.ResponseWriter, request *http.Request) {
+	v := validator.New()
+	qs := request.URL.Query()
+
+	var source, externalID string
+	given := 0
+
+	for name, rx := range data.ExternalIDSources {
+		if !qs.Has(name) {
+			continue
+		}
+
+		source, externalID = name, qs.Get(name)
+		given++
+
+		v.Check(validator.Matches(externalID, rx), name, fmt.Sprintf("must be a valid %s ID", name))
+	}
+
+	v.Check(given == 1, "external_id", "exactly one of imdb, tmdb or wikidata must be provided")
+
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.GetByExternalID(source, externalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	app.formatMovies(runtimeFormat, movie)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+// mergeMovieHandler folds the movie, a duplicate, into the movie given in the
+// request body and deletes it. The target keeps its own fields and images.
+func (app *application) mergeMovieHandler(writer http.ResponseWriter, request *http.Request) {
+	id, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	var input struct {
+		Into int64 `json:"into"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Into > 0, "into", "must be a positive integer")
+	v.Check(input.Into != id, "into", "must not be the merged movie itself")
+
+	runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	duplicate, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	if !app.checkIfMatch(writer, request, movieETag(duplicate)) {
+		return
+	}
+
+	target, err := app.models.Movies.Get(input.Into)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("into", "no matching movie found")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	genres, err := app.models.Genres.GetAllSlugs()
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	// the merged movie gets the genres of both, which must still be valid
+	merged := *target
+	merged.Genres = data.MergeGenres(target, duplicate)
+
+	if data.ValidateMovie(v, &merged, genres); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.Merge(duplicate, target, app.contextGetUser(request).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	app.deleteMovieImageFiles(duplicate.ID)
+
+	target, err = app.models.Movies.Get(target.ID)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(target))
+
+	app.formatMovies(runtimeFormat, target)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"movie": target}, headers)
 	if err != nil {
 		app.serverErrorResponse(writer, request, err)
 	}
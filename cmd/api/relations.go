@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+// listRelationsHandler returns the relations from and to the movie, so that
+// e.g. both a movie's sequels and the movie it is a sequel of are listed.
+func (app *application) listRelationsHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	relations, err := app.models.Relations.GetAllForMovie(movieID)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"relations": relations}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+// createRelationHandler relates the movie to the movie given in the request
+// body, e.g. as its sequel.
+func (app *application) createRelationHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	var input struct {
+		Type           string `json:"type"`
+		RelatedMovieID int64  `json:"related_movie_id"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	relation := &data.Relation{
+		MovieID:        movieID,
+		Type:           input.Type,
+		RelatedMovieID: input.RelatedMovieID,
+	}
+
+	v := validator.New()
+
+	if data.ValidateRelation(v, relation); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	_, err = app.models.Movies.Get(relation.RelatedMovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("related_movie_id", "no matching movie found")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.models.Relations.Insert(relation)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRelation):
+			v.AddError("related_movie_id", "the movies are already related in this way")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusCreated, envelope{"relation": relation}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) deleteRelationHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	relationID, err := app.readNamedIDParameter(request, "relation_id")
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	err = app.models.Relations.Delete(movieID, relationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"message": "relation successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
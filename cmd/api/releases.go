@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+func (app *application) listReleasesHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	releases, err := app.models.Releases.GetAllForMovie(movieID)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"releases": releases}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) createReleaseHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	var input struct {
+		Country       string    `json:"country"`
+		Type          string    `json:"type"`
+		Date          data.Date `json:"date"`
+		Certification string    `json:"certification"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	release := &data.Release{
+		MovieID:       movieID,
+		Country:       input.Country,
+		Type:          input.Type,
+		Date:          input.Date,
+		Certification: input.Certification,
+	}
+
+	v := validator.New()
+
+	if data.ValidateRelease(v, release); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	err = app.models.Releases.Insert(release)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRelease):
+			v.AddError("type", "a release of this type exists already in this country")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusCreated, envelope{"release": release}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) deleteReleaseHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	releaseID, err := app.readNamedIDParameter(request, "release_id")
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	err = app.models.Releases.Delete(movieID, releaseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"message": "release successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+func (app *application) listMovieRevisionsHandler(writer http.ResponseWriter, request *http.Request) {
+	id, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := request.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"created_at", "version", "-created_at", "-version"}
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.Revisions.GetAllForMovie(id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	app.formatRevisions(runtimeFormat, revisions...)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) showMovieRevisionHandler(writer http.ResponseWriter, request *http.Request) {
+	id, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	version, err := app.readNamedIDParameter(request, "version")
+	if err != nil || version > math.MaxInt32 {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	revision, err := app.models.Revisions.GetVersion(id, int32(version))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	app.formatRevisions(runtimeFormat, revision)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) revertMovieHandler(writer http.ResponseWriter, request *http.Request) {
+	id, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	// a revert overwrites every field of the movie, so unlike other edits it
+	// always requires the client to name the version it means to replace
+	if request.Header.Get("If-Match") == "" {
+		app.preconditionRequiredResponse(writer, request)
+		return
+	}
+
+	if !app.checkIfMatch(writer, request, movieETag(movie)) {
+		return
+	}
+
+	var input struct {
+		Version int32 `json:"version"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Version > 0, "version", "must be provided")
+	v.Check(input.Version != movie.Version, "version", "must not be the current version")
+
+	runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	revision, err := app.models.Revisions.GetVersion(movie.ID, input.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("version", "no matching revision found")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	// the reverted state is saved as a new version on top of the current one
+	movie.Title = revision.Title
+	movie.Year = revision.Year
+	movie.RuntimeMin = revision.RuntimeMin
+	movie.Genres = revision.Genres
+
+	genres, err := app.models.Genres.GetAllSlugs()
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.Update(movie, app.contextGetUser(request).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	app.formatMovies(runtimeFormat, movie)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
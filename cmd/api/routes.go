@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/mwettste/greenlight/internal/data"
 )
 
 func (app *application) routes() http.Handler {
@@ -15,15 +16,83 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.idempotent(app.createMovieHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id", app.fixedPathOrID(map[string]http.HandlerFunc{
+		"import": app.requirePermission("movies:write", app.importMoviesHandler),
+	}, app.methodNotAllowedResponse))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.fixedPathOrID(map[string]http.HandlerFunc{
+		"autocomplete": app.requirePermission("movies:read", app.autocompleteMoviesHandler),
+		"trash":        app.requirePermission("movies:write", app.listTrashHandler),
+		"export":       app.requirePermission("movies:read", app.exportMoviesHandler),
+		"lookup":       app.requirePermission("movies:read", app.lookupMovieHandler),
+	}, app.requirePermission("movies:read", app.showMovieHandler)))
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMoveHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.requirePermission("movies:read", app.listSimilarMoviesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission("movies:write", app.restoreMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/purge", app.requirePermission("movies:admin", app.purgeMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/merge", app.requirePermission("movies:admin", app.mergeMovieHandler))
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/poster", app.requirePermission("movies:write", app.uploadMovieImageHandler(data.ImagePoster)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/poster", app.requirePermission("movies:write", app.deleteMovieImageHandler(data.ImagePoster)))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/backdrop", app.requirePermission("movies:write", app.uploadMovieImageHandler(data.ImageBackdrop)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/backdrop", app.requirePermission("movies:write", app.deleteMovieImageHandler(data.ImageBackdrop)))
+
+	// images are referenced by unguessable URLs which browsers load without credentials
+	router.HandlerFunc(http.MethodGet, "/v1/images/:movie_id/:token/:variant", app.showImageHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/translations", app.requirePermission("movies:read", app.listTranslationsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/translations/:locale", app.requirePermission("movies:write", app.putTranslationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/translations/:locale", app.requirePermission("movies:write", app.deleteTranslationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions", app.requirePermission("movies:read", app.listMovieRevisionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions/:version", app.requirePermission("movies:read", app.showMovieRevisionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revert", app.requirePermission("movies:write", app.revertMovieHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requirePermission("reviews:write", app.idempotent(app.createReviewHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/reviews/:review_id", app.requirePermission("reviews:write", app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/reviews/:review_id", app.requirePermission("reviews:write", app.deleteReviewHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.requirePermission("movies:read", app.listCreditsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermission("movies:write", app.idempotent(app.createCreditHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requirePermission("movies:write", app.deleteCreditHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/relations", app.requirePermission("movies:read", app.listRelationsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/relations", app.requirePermission("movies:write", app.createRelationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/relations/:relation_id", app.requirePermission("movies:write", app.deleteRelationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/releases", app.requirePermission("movies:read", app.listReleasesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/releases", app.requirePermission("movies:write", app.createReleaseHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/releases/:release_id", app.requirePermission("movies:write", app.deleteReleaseHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("movies:admin", app.createGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:slug", app.requirePermission("movies:admin", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:slug/merge", app.requirePermission("movies:admin", app.mergeGenreHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/collections", app.requirePermission("movies:read", app.listCollectionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermission("collections:write", app.idempotent(app.createCollectionHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.requirePermission("movies:read", app.showCollectionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermission("collections:write", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermission("collections:write", app.deleteCollectionHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.requirePermission("movies:read", app.listPeopleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("movies:write", app.idempotent(app.createPersonHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.requirePermission("movies:read", app.showPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("movies:write", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("movies:write", app.deletePersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id/movies", app.requirePermission("movies:read", app.listPersonMoviesHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.idempotent(app.registerUserHandler))
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
 
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/watchlist", app.requirePermission("movies:read", app.listWatchlistHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/watchlist", app.requirePermission("movies:read", app.idempotent(app.addToWatchlistHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/watchlist/:id", app.requirePermission("movies:read", app.updateWatchlistEntryHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/watchlist/:id", app.requirePermission("movies:read", app.removeFromWatchlistHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
 
@@ -32,3 +101,18 @@ func (app *application) routes() http.Handler {
 
 	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
 }
+
+// fixedPathOrID works around httprouter not allowing fixed path segments next to
+// a wildcard: requests whose :id parameter matches one of the fixed paths are
+// passed to the respective handler, all others to byID.
+func (app *application) fixedPathOrID(fixed map[string]http.HandlerFunc, byID http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		params := httprouter.ParamsFromContext(request.Context())
+		if handler, ok := fixed[params.ByName("id")]; ok {
+			handler(writer, request)
+			return
+		}
+
+		byID(writer, request)
+	}
+}
@@ -33,6 +33,8 @@ func (app *application) serve() error {
 			"signal": s.String(),
 		})
 
+		close(app.shutdown)
+
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+	"golang.org/x/text/language"
+)
+
+func (app *application) listTranslationsHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	translations, err := app.models.Translations.GetAllForMovie(movieID)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"translations": translations}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+// putTranslationHandler creates or replaces the movie's translation into the
+// locale given in the path.
+func (app *application) putTranslationHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	locale, err := readLocaleParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	var input struct {
+		Title    string `json:"title"`
+		Synopsis string `json:"synopsis"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	translation := &data.MovieTranslation{
+		MovieID:  movieID,
+		Locale:   locale,
+		Title:    input.Title,
+		Synopsis: input.Synopsis,
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieTranslation(v, translation); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	err = app.models.Translations.Upsert(translation)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"translation": translation}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) deleteTranslationHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	locale, err := readLocaleParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	err = app.models.Translations.Delete(movieID, locale)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"message": "translation successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+// readLocaleParameter reads the locale from the path in its canonical form,
+// e.g. "de-ch" becomes "de-CH".
+func readLocaleParameter(request *http.Request) (string, error) {
+	params := httprouter.ParamsFromContext(request.Context())
+
+	tag, err := language.Parse(params.ByName("locale"))
+	if err != nil {
+		return "", err
+	}
+
+	return tag.String(), nil
+}
+
+// readLocales returns the locales the client prefers, taken from the lang query
+// string parameter or else the Accept-Language header, most preferred first.
+// Every locale is followed by its more general parents, so that asking for
+// "de-CH" also finds translations into "de".
+func (app *application) readLocales(request *http.Request, v *validator.Validator) []string {
+	var tags []language.Tag
+
+	if lang := request.URL.Query().Get("lang"); lang != "" {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			v.AddError("lang", "must be a valid language tag")
+			return nil
+		}
+
+		tags = []language.Tag{tag}
+	} else {
+		// a malformed header is ignored like a missing one, as browsers send it unasked
+		tags, _, _ = language.ParseAcceptLanguage(request.Header.Get("Accept-Language"))
+	}
+
+	locales := []string{}
+
+	for _, tag := range tags {
+		for ; tag != language.Und; tag = tag.Parent() {
+			if !validator.PermittedValue(tag.String(), locales...) {
+				locales = append(locales, tag.String())
+			}
+		}
+	}
+
+	return locales
+}
+
+// localizeMovies replaces the titles of the movies with the best matching
+// translations and announces the languages used in the Content-Language header.
+// Movies without a matching translation keep their original title.
+func (app *application) localizeMovies(writer http.ResponseWriter, movies []*data.Movie, locales []string) error {
+	writer.Header().Add("Vary", "Accept-Language")
+
+	used, err := app.models.Translations.Localize(movies, locales)
+	if err != nil {
+		return err
+	}
+
+	if len(used) > 0 {
+		writer.Header().Set("Content-Language", strings.Join(used, ", "))
+	}
+
+	return nil
+}
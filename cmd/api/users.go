@@ -54,7 +54,7 @@ func (app *application) registerUserHandler(writer http.ResponseWriter, request
 		return
 	}
 
-	err = app.models.Permissions.AddForUser(user.ID, "movies:read")
+	err = app.models.Permissions.AddForUser(user.ID, "movies:read", "reviews:write")
 	if err != nil {
 		app.serverErrorResponse(writer, request, err)
 		return
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mwettste/greenlight/internal/data"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+func (app *application) listWatchlistHandler(writer http.ResponseWriter, request *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := request.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-added_at")
+	input.Filters.SortSafelist = []string{"added_at", "title", "year", "-added_at", "-title", "-year"}
+	runtimeFormat := app.readRuntimeFormat(qs, v)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(request)
+
+	entries, metadata, err := app.models.Watchlist.GetAllForUser(user.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+		return
+	}
+
+	for _, entry := range entries {
+		app.formatMovies(runtimeFormat, entry.Movie)
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"watchlist": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) addToWatchlistHandler(writer http.ResponseWriter, request *http.Request) {
+	var input struct {
+		MovieID   int64      `json:"movie_id"`
+		WatchedOn *data.Date `json:"watched_on"`
+	}
+
+	err := app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.MovieID > 0, "movie_id", "must be provided")
+
+	runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(input.MovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "no matching movie found")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	entry := &data.WatchlistEntry{
+		UserID:    app.contextGetUser(request).ID,
+		Movie:     movie,
+		WatchedOn: input.WatchedOn,
+	}
+
+	if data.ValidateWatchlistEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlist.Insert(entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateWatchlistEntry):
+			v.AddError("movie_id", "movie is already on your watchlist")
+			app.failedValidationResponse(writer, request, v.Errors)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	app.formatMovies(runtimeFormat, entry.Movie)
+
+	err = app.writeJSON(writer, http.StatusCreated, envelope{"watchlist_entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) updateWatchlistEntryHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	user := app.contextGetUser(request)
+
+	entry, err := app.models.Watchlist.Get(user.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	var input struct {
+		Watched   *bool      `json:"watched"`
+		WatchedOn *data.Date `json:"watched_on"`
+	}
+
+	err = app.readJSON(writer, request, &input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	switch {
+	case input.Watched != nil && !*input.Watched:
+		entry.WatchedOn = nil
+	case input.WatchedOn != nil:
+		entry.WatchedOn = input.WatchedOn
+	case input.Watched != nil && entry.WatchedOn == nil:
+		// marking an entry as watched without a date means it was watched today
+		today := data.Today()
+		entry.WatchedOn = &today
+	}
+
+	v := validator.New()
+	runtimeFormat := app.readRuntimeFormat(request.URL.Query(), v)
+
+	if data.ValidateWatchlistEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(writer, request, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlist.Update(entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	app.formatMovies(runtimeFormat, entry.Movie)
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"watchlist_entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
+
+func (app *application) removeFromWatchlistHandler(writer http.ResponseWriter, request *http.Request) {
+	movieID, err := app.readIDParameter(request)
+	if err != nil {
+		app.notFoundResponse(writer, request)
+		return
+	}
+
+	user := app.contextGetUser(request)
+
+	err = app.models.Watchlist.Delete(user.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(writer, request)
+		default:
+			app.serverErrorResponse(writer, request, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"message": "movie successfully removed from watchlist"}, nil)
+	if err != nil {
+		app.serverErrorResponse(writer, request, err)
+	}
+}
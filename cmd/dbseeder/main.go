@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/mwettste/greenlight/internal/data"
@@ -57,13 +59,6 @@ var sampleMovies = []data.Movie{
 	},
 }
 
-var filters = data.Filters{
-	Page:         1,
-	PageSize:     5,
-	Sort:         "id",
-	SortSafelist: []string{"id"},
-}
-
 func main() {
 	var dsn string
 	flag.StringVar(&dsn, "dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
@@ -75,25 +70,32 @@ func main() {
 
 	models := data.NewModels(db)
 
+	// movies may only use managed genres, so the sample genres are created first
+	for _, movie := range sampleMovies {
+		for _, slug := range movie.Genres {
+			genre := data.Genre{Slug: slug, Name: strings.ToUpper(slug[:1]) + slug[1:]}
+
+			err = models.Genres.Insert(&genre)
+			if err != nil && !errors.Is(err, data.ErrDuplicateGenre) {
+				log.Fatalf("Failed to insert genre: %v\n", err)
+			}
+		}
+	}
+
 	noOfMovies := len(sampleMovies)
 	for i, movie := range sampleMovies {
 		fmt.Printf("Inserting movie %d of %d with title %s\n", i+1, noOfMovies, movie.Title)
-		_, metadata, err := models.Movies.GetAll(movie.Title, []string{}, filters)
-
+		duplicates, err := models.Movies.FindDuplicates(&movie)
 		if err != nil {
 			log.Fatalf("Failed to check if movie exists: %v\n", err)
 		}
 
-		if metadata.TotalRecords > 1 {
-			log.Fatalf("Found multiple records matching the title: %s\n", movie.Title)
-		}
-
-		if metadata.TotalRecords == 1 {
+		if len(duplicates) > 0 {
 			fmt.Println("Movie already in DB...")
 			continue
 		}
 
-		err = models.Movies.Insert(&movie)
+		err = models.Movies.Insert(&movie, 0)
 		if err != nil {
 			log.Fatalf("Failed to insert movie: %v\n", err)
 		}
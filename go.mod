@@ -1,13 +1,15 @@
 module github.com/mwettste/greenlight
 
-go 1.18
+go 1.20
 
 require (
-	github.com/felixge/httpsnoop v1.0.2
+	github.com/felixge/httpsnoop v1.0.4
 	github.com/go-mail/mail/v2 v2.3.0
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.2
 	golang.org/x/crypto v0.0.0-20220513210258-46612604a0f9
+	golang.org/x/image v0.10.0
+	golang.org/x/text v0.11.0
 	golang.org/x/time v0.0.0-20220411224347-583f2d630306
 )
 
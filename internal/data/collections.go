@@ -0,0 +1,378 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+var ErrUnknownMovie = errors.New("unknown movie")
+
+// Collection groups movies, e.g. the entries of a franchise, in a given order.
+// MovieIDs lists its members, not counting those in the trash, in that order.
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MovieIDs    []int64   `json:"movie_ids"`
+	Movies      []*Movie  `json:"movies,omitempty"`
+	Version     int32     `json:"version"`
+}
+
+// MovieCollection is a collection as listed on one of its movies, together
+// with the movie's position within it.
+type MovieCollection struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int32  `json:"position"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(len(collection.Description) <= 10_000, "description", "must not be more than 10000 bytes long")
+
+	v.Check(len(collection.MovieIDs) <= 500, "movie_ids", "must not contain more than 500 movies")
+	v.Check(validator.Unique(collection.MovieIDs), "movie_ids", "must not contain duplicate values")
+
+	for _, id := range collection.MovieIDs {
+		v.Check(id > 0, "movie_ids", "must only contain positive integers")
+	}
+}
+
+// collectionMovieIDsSubquery lists the IDs of a collection's movies in order.
+const collectionMovieIDsSubquery = `
+	ARRAY(
+		SELECT collection_movies.movie_id
+		FROM collection_movies
+		INNER JOIN movies ON movies.id = collection_movies.movie_id
+		WHERE collection_movies.collection_id = collections.id AND movies.deleted_at IS NULL
+		ORDER BY collection_movies.position, collection_movies.movie_id
+	)`
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	query := `
+	INSERT INTO collections (name, description)
+	VALUES ($1, $2)
+	RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, collection.Name, collection.Description).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+	if err != nil {
+		return err
+	}
+
+	err = setCollectionMovies(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, description, ` + collectionMovieIDsSubquery + `, version
+		FROM collections
+		WHERE id = $1`
+
+	var collection Collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Name,
+		&collection.Description,
+		pq.Array(&collection.MovieIDs),
+		&collection.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &collection, nil
+}
+
+// Update saves the collection's name, description and members if it hasn't
+// been changed since it was read.
+func (m CollectionModel) Update(collection *Collection) error {
+	query := `
+		UPDATE collections
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+
+	args := []interface{}{
+		collection.Name,
+		collection.Description,
+		collection.ID,
+		collection.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = setCollectionMovies(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM collections
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m CollectionModel) GetAll(name string, filters Filters) ([]*Collection, FilterMetadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, description, `+collectionMovieIDsSubquery+`, version
+		FROM collections
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	defer rows.Close()
+	collections := []*Collection{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.Name,
+			&collection.Description,
+			pq.Array(&collection.MovieIDs),
+			&collection.Version,
+		)
+
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return collections, metadata, nil
+}
+
+// GetMovies returns the collection's movies, not counting those in the trash,
+// in their order within the collection.
+func (m CollectionModel) GetMovies(collectionID int64) ([]*Movie, error) {
+	query := `
+		SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+			ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids, movies.version
+		FROM collection_movies
+		INNER JOIN movies ON movies.id = collection_movies.movie_id
+		LEFT JOIN LATERAL (` + ratingsSubquery + `) ratings ON true
+		LEFT JOIN LATERAL (` + imagesSubquery + `) images ON true
+		LEFT JOIN LATERAL (` + externalIDsSubquery + `) external_ids ON true
+		WHERE collection_movies.collection_id = $1 AND movies.deleted_at IS NULL
+		ORDER BY collection_movies.position, movies.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.RuntimeMin,
+			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Poster,
+			&movie.Backdrop,
+			&movie.ExternalIDs,
+			&movie.Version,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// GetAllForMovie returns the collections the movie belongs to, ordered by name.
+func (m CollectionModel) GetAllForMovie(movieID int64) ([]*MovieCollection, error) {
+	query := `
+		SELECT collections.id, collections.name, collection_movies.position
+		FROM collection_movies
+		INNER JOIN collections ON collections.id = collection_movies.collection_id
+		WHERE collection_movies.movie_id = $1
+		ORDER BY collections.name, collections.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	collections := []*MovieCollection{}
+
+	for rows.Next() {
+		var collection MovieCollection
+		err := rows.Scan(&collection.ID, &collection.Name, &collection.Position)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// setCollectionMovies replaces the collection's members with its MovieIDs, in
+// that order, as part of the transaction which saves the collection. Members
+// which are in the trash are kept at their position, as they are not visible to
+// the client. It returns ErrUnknownMovie if any of the movies doesn't exist.
+func setCollectionMovies(ctx context.Context, tx *sql.Tx, collection *Collection) error {
+	query := `
+		DELETE FROM collection_movies
+		USING movies
+		WHERE collection_movies.collection_id = $1 AND movies.id = collection_movies.movie_id AND movies.deleted_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, collection.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(collection.MovieIDs) == 0 {
+		return nil
+	}
+
+	query = `
+		INSERT INTO collection_movies (collection_id, movie_id, position)
+		SELECT $1, members.movie_id, members.position
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS members (movie_id, position)
+		INNER JOIN movies ON movies.id = members.movie_id AND movies.deleted_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, collection.ID, pq.Array(collection.MovieIDs))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(collection.MovieIDs)) {
+		return ErrUnknownMovie
+	}
+
+	return nil
+}
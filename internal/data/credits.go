@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+const (
+	RoleDirector = "director"
+	RoleActor    = "actor"
+	RoleWriter   = "writer"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("duplicate credit")
+)
+
+type Credit struct {
+	ID         int64  `json:"id"`
+	MovieID    int64  `json:"-"`
+	PersonID   int64  `json:"person_id"`
+	PersonName string `json:"name"`
+	Role       string `json:"role"`
+	Character  string `json:"character,omitempty"`
+}
+
+type FilmographyEntry struct {
+	Movie     *Movie `json:"movie"`
+	Role      string `json:"role"`
+	Character string `json:"character,omitempty"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(validator.PermittedValue(credit.Role, RoleDirector, RoleActor, RoleWriter), "role", "must be one of director, actor or writer")
+
+	v.Check(credit.Character == "" || credit.Role == RoleActor, "character", "must only be provided for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+}
+
+type CreditModel struct {
+	DB *sql.DB
+}
+
+func (m CreditModel) Insert(credit *Credit) error {
+	query := `
+	INSERT INTO movie_credits (movie_id, person_id, role, character)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, (SELECT name FROM people WHERE id = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{credit.MovieID, credit.PersonID, credit.Role, credit.Character}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.PersonName)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_credits_movie_id_person_id_role_character_key"`:
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CreditModel) Delete(movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_credits
+		WHERE movie_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m CreditModel) GetAllForMovie(movieID int64) ([]*Credit, error) {
+	query := `
+		SELECT movie_credits.id, movie_credits.movie_id, movie_credits.person_id, people.name, movie_credits.role, movie_credits.character
+		FROM movie_credits
+		INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.movie_id = $1
+		ORDER BY movie_credits.role, movie_credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	credits := []*Credit{}
+
+	for rows.Next() {
+		var credit Credit
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.PersonName,
+			&credit.Role,
+			&credit.Character,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+func (m CreditModel) GetFilmography(personID int64, filters Filters) ([]*FilmographyEntry, FilterMetadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), movie_credits.role, movie_credits.character,
+			movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+			ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids, movies.version
+		FROM movie_credits
+		INNER JOIN movies ON movies.id = movie_credits.movie_id
+		LEFT JOIN LATERAL (`+ratingsSubquery+`) ratings ON true
+		LEFT JOIN LATERAL (`+imagesSubquery+`) images ON true
+		LEFT JOIN LATERAL (`+externalIDsSubquery+`) external_ids ON true
+		WHERE movie_credits.person_id = $1 AND movies.deleted_at IS NULL
+		ORDER BY %s %s, movies.id ASC, movie_credits.id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	defer rows.Close()
+	entries := []*FilmographyEntry{}
+	totalRecords := 0
+
+	for rows.Next() {
+		entry := FilmographyEntry{Movie: &Movie{}}
+		err := rows.Scan(
+			&totalRecords,
+			&entry.Role,
+			&entry.Character,
+			&entry.Movie.ID,
+			&entry.Movie.CreatedAt,
+			&entry.Movie.Title,
+			&entry.Movie.Year,
+			&entry.Movie.RuntimeMin,
+			pq.Array(&entry.Movie.Genres),
+			&entry.Movie.AverageRating,
+			&entry.Movie.RatingCount,
+			&entry.Movie.Poster,
+			&entry.Movie.Backdrop,
+			&entry.Movie.ExternalIDs,
+			&entry.Movie.Version,
+		)
+
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
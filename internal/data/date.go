@@ -0,0 +1,78 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Date is a calendar day without a time of day, which is read from and
+// written to JSON as "YYYY-MM-DD".
+type Date struct {
+	time.Time
+}
+
+var ErrInvalidDateFormat = errors.New("invalid date format")
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, ErrInvalidDateFormat
+	}
+
+	return Date{Time: t}, nil
+}
+
+func Today() Date {
+	now := time.Now()
+	return Date{Time: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(data))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	parsed, err := ParseDate(unquotedJSONValue)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Date) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case time.Time:
+		d.Time = time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+		return nil
+	case string:
+		parsed, err := ParseDate(value)
+		*d = parsed
+		return err
+	case []byte:
+		parsed, err := ParseDate(string(value))
+		*d = parsed
+		return err
+	default:
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+}
@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+// normalizedTitle reduces a title to its lowercase letters and digits without
+// a leading article, so that "The Matrix" and "Matrix, The" compare equal.
+const normalizedTitle = `regexp_replace(regexp_replace(lower(%[1]s), '^(the|a|an)\s+|,\s*(the|a|an)$', '', 'g'), '[^[:alnum:]]+', '', 'g')`
+
+// duplicateCondition matches the movies, not counting those in the trash,
+// which are probably the same as a movie with the given title (%[1]s) and year
+// (%[2]s): they were released in the same year and their titles are equal once
+// normalized or at least very similar by trigram similarity.
+var duplicateCondition = fmt.Sprintf(`year = %%[2]s AND deleted_at IS NULL
+			AND (%s = %s OR similarity(lower(title), lower(%%[1]s)) >= 0.6)`,
+	fmt.Sprintf(normalizedTitle, "title"), fmt.Sprintf(normalizedTitle, "%[1]s"))
+
+// FindDuplicates returns the IDs of the movies which are probably the same as
+// the given one, as matched by duplicateCondition. The most similar come first.
+func (m MovieModel) FindDuplicates(movie *Movie) ([]int64, error) {
+	query := fmt.Sprintf(`
+		SELECT id
+		FROM movies
+		WHERE id <> $3 AND %s
+		ORDER BY similarity(lower(title), lower($1)) DESC, id ASC
+		LIMIT 10`, fmt.Sprintf(duplicateCondition, "$1", "$2"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movie.Title, movie.Year, movie.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// mergeQueries re-point the rows referring to the duplicate ($1) to the target
+// ($2). Rows which the target has an equivalent of already are left behind and
+// deleted along with the duplicate.
+var mergeQueries = []string{
+	`UPDATE reviews SET movie_id = $2
+		WHERE movie_id = $1 AND user_id NOT IN (SELECT user_id FROM reviews WHERE movie_id = $2)`,
+	`UPDATE watchlist_entries SET movie_id = $2
+		WHERE movie_id = $1 AND user_id NOT IN (SELECT user_id FROM watchlist_entries WHERE movie_id = $2)`,
+	`UPDATE movie_credits SET movie_id = $2
+		WHERE movie_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM movie_credits target
+			WHERE target.movie_id = $2 AND target.person_id = movie_credits.person_id
+				AND target.role = movie_credits.role AND target.character = movie_credits.character
+		)`,
+	`UPDATE movie_external_ids SET movie_id = $2
+		WHERE movie_id = $1 AND source NOT IN (SELECT source FROM movie_external_ids WHERE movie_id = $2)`,
+	`UPDATE movie_translations SET movie_id = $2
+		WHERE movie_id = $1 AND locale NOT IN (SELECT locale FROM movie_translations WHERE movie_id = $2)`,
+	`UPDATE collection_movies SET movie_id = $2
+		WHERE movie_id = $1 AND collection_id NOT IN (SELECT collection_id FROM collection_movies WHERE movie_id = $2)`,
+	`UPDATE movie_releases SET movie_id = $2
+		WHERE movie_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM movie_releases target
+			WHERE target.movie_id = $2 AND target.country = movie_releases.country AND target.type = movie_releases.type
+		)`,
+	`UPDATE movie_relations SET movie_id = $2
+		WHERE movie_id = $1 AND related_movie_id <> $2 AND NOT EXISTS (
+			SELECT 1 FROM movie_relations target
+			WHERE target.movie_id = $2 AND target.type = movie_relations.type AND target.related_movie_id = movie_relations.related_movie_id
+		)`,
+	`UPDATE movie_relations SET related_movie_id = $2
+		WHERE related_movie_id = $1 AND movie_id <> $2 AND NOT EXISTS (
+			SELECT 1 FROM movie_relations target
+			WHERE target.related_movie_id = $2 AND target.type = movie_relations.type AND target.movie_id = movie_relations.movie_id
+		)`,
+}
+
+// MergeGenres returns the union of the target's and the duplicate's genres,
+// which the target has after merging. Callers must validate it beforehand, as
+// it may hold more genres than a movie is allowed to have.
+func MergeGenres(target, duplicate *Movie) []string {
+	genres := append([]string{}, target.Genres...)
+	for _, genre := range duplicate.Genres {
+		if !validator.PermittedValue(genre, genres...) {
+			genres = append(genres, genre)
+		}
+	}
+
+	return genres
+}
+
+// Merge folds the duplicate into the target movie within a single transaction:
+// the target gets the union of both movies' genres, the duplicate's reviews,
+// watchlist entries, credits, external IDs, translations, releases,
+// collection memberships and relations are moved to the target unless it has
+// equivalent ones, and the duplicate is deleted along with everything left
+// behind, including its revisions and images. Both movies must not have been
+// changed since they were read.
+func (m MovieModel) Merge(duplicate, target *Movie, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the duplicate is locked, so that nothing is added to it while its rows are moved
+	query := `
+		SELECT id
+		FROM movies
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, query, duplicate.ID, duplicate.Version).Scan(&duplicate.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	genres := MergeGenres(target, duplicate)
+
+	query = `
+		UPDATE movies
+		SET genres = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+		RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, pq.Array(genres), target.ID, target.Version).Scan(&target.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	target.Genres = genres
+
+	for _, query := range mergeQueries {
+		_, err = tx.ExecContext(ctx, query, duplicate.ID, target.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	query = `
+		DELETE FROM movies
+		WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, query, duplicate.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, target, RevisionUpdate, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+var (
+	ErrDuplicateExternalID = errors.New("duplicate external id")
+
+	// ExternalIDSources maps the supported external datasets to the format of their IDs.
+	ExternalIDSources = map[string]*regexp.Regexp{
+		"imdb":     validator.IMDbIDRX,
+		"tmdb":     validator.TMDbIDRX,
+		"wikidata": validator.WikidataIDRX,
+	}
+)
+
+// ExternalIDs maps the name of an external dataset to the ID the movie has
+// there. Every ID belongs to a single movie per dataset.
+type ExternalIDs map[string]string
+
+func ValidateExternalIDs(v *validator.Validator, ids ExternalIDs) {
+	for source, id := range ids {
+		rx, ok := ExternalIDSources[source]
+		if !ok {
+			v.AddError("external_ids", fmt.Sprintf("%q is not a supported source", source))
+			continue
+		}
+
+		v.Check(validator.Matches(id, rx), "external_ids", fmt.Sprintf("%q is not a valid %s ID", id, source))
+	}
+}
+
+func (ids *ExternalIDs) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*ids = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, ids)
+	case string:
+		return json.Unmarshal([]byte(value), ids)
+	default:
+		return fmt.Errorf("cannot scan %T into ExternalIDs", src)
+	}
+}
+
+// externalIDsSubquery collects the external IDs of a single movie into a JSON
+// object and is meant to be used in a LATERAL join against the movies table.
+const externalIDsSubquery = `
+		SELECT jsonb_object_agg(movie_external_ids.source, movie_external_ids.external_id) AS ids
+		FROM movie_external_ids
+		WHERE movie_external_ids.movie_id = movies.id`
+
+// setExternalIDs replaces the external IDs of the movie as part of the
+// transaction which saves it.
+func setExternalIDs(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	query := `
+		DELETE FROM movie_external_ids
+		WHERE movie_id = $1`
+
+	_, err := tx.ExecContext(ctx, query, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO movie_external_ids (movie_id, source, external_id)
+		VALUES ($1, $2, $3)`
+
+	for source, id := range movie.ExternalIDs {
+		_, err = tx.ExecContext(ctx, query, movie.ID, source, id)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "movie_external_ids_source_external_id_key"`:
+				return ErrDuplicateExternalID
+			default:
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MovieFacets lists the aggregates GetFacets can compute.
+var MovieFacets = []string{"genres", "decade", "runtime"}
+
+// facetQueries counts the movies matching a WHERE clause per value of each
+// facet. Genres are ordered by popularity, decades and runtimes by value.
+var facetQueries = map[string]string{
+	"genres": `
+		SELECT 'genres' AS facet, genre AS value, count(*) AS count, -count(*) AS position
+		FROM movies, unnest(movies.genres) AS genre
+		WHERE %s
+		GROUP BY genre`,
+	"decade": `
+		SELECT 'decade' AS facet, ((year / 10) * 10) || 's' AS value, count(*) AS count, (year / 10) * 10 AS position
+		FROM movies
+		WHERE %s
+		GROUP BY (year / 10) * 10`,
+	"runtime": `
+		SELECT 'runtime' AS facet, bucket AS value, count(*) AS count, min(runtime) AS position
+		FROM (
+			SELECT runtime, CASE
+				WHEN runtime < 90 THEN '0-89'
+				WHEN runtime < 120 THEN '90-119'
+				WHEN runtime < 150 THEN '120-149'
+				WHEN runtime < 180 THEN '150-179'
+				ELSE '180+'
+			END AS bucket
+			FROM movies
+			WHERE %s
+		) runtimes
+		GROUP BY bucket`,
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// GetFacets counts the movies matching the criteria, using the same WHERE
+// clause as GetAll, per value of each of the given facets.
+func (m MovieModel) GetFacets(criteria MovieCriteria, facets []string) (map[string][]*FacetCount, error) {
+	where := criteria.where()
+	result := make(map[string][]*FacetCount, len(facets))
+	parts := []string{}
+
+	for _, facet := range facets {
+		query, ok := facetQueries[facet]
+		if !ok {
+			return nil, fmt.Errorf("unknown facet %q", facet)
+		}
+
+		if _, ok := result[facet]; !ok {
+			result[facet] = []*FacetCount{}
+			parts = append(parts, fmt.Sprintf(query, where))
+		}
+	}
+
+	if len(parts) == 0 {
+		return result, nil
+	}
+
+	// every facet is computed in the same round trip, sharing the WHERE clause's arguments
+	query := strings.Join(parts, "\n\t\tUNION ALL") + `
+		ORDER BY facet, position, value`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet string
+		var position int64
+		var count FacetCount
+
+		err := rows.Scan(&facet, &count.Value, &count.Count, &position)
+		if err != nil {
+			return nil, err
+		}
+
+		result[facet] = append(result[facet], &count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
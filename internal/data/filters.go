@@ -1,25 +1,86 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/mwettste/greenlight/internal/validator"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// CursorMode switches from offset to keyset pagination, in which case
+	// Cursor holds the opaque position to continue from (empty for the first page).
+	CursorMode bool
+	Cursor     string
 }
 
 type FilterMetadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// cursor identifies the position of a row within a listing sorted by Sort,
+// with the row id used as a tie-breaker. Backward cursors page towards the start.
+type cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int64  `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 || !c.validValue() {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// validValue reports whether the cursor's value is of the type of its sort
+// column, as it is compared against the column in SQL. Numbers must be
+// formatted the way movieSortValue does, which rules out e.g. NaN or hex floats.
+func (c cursor) validValue() bool {
+	switch strings.TrimPrefix(c.Sort, "-") {
+	case "id":
+		i, err := strconv.ParseInt(c.Value, 10, 64)
+		return err == nil && strconv.FormatInt(i, 10) == c.Value
+	case "year", "runtime":
+		i, err := strconv.ParseInt(c.Value, 10, 32)
+		return err == nil && strconv.FormatInt(i, 10) == c.Value
+	case "rating", "relevance":
+		f, err := strconv.ParseFloat(c.Value, 64)
+		return err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) && strconv.FormatFloat(f, 'f', -1, 64) == c.Value
+	default:
+		return true
+	}
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -31,6 +92,12 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 
 	// Check that the sort parameter matches a value in the safelist.
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	if f.CursorMode && f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "invalid cursor value")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "does not match the sort parameter")
+	}
 }
 
 func (f Filters) sortColumn() string {
@@ -59,6 +126,21 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+// keyset returns the comparison operator and ORDER BY direction for the keyset
+// condition, taking into account whether the cursor pages backward.
+func (f Filters) keyset(backward bool) (string, string) {
+	ascending := f.sortDirection() == "ASC"
+	if backward {
+		ascending = !ascending
+	}
+
+	if ascending {
+		return ">", "ASC"
+	}
+
+	return "<", "DESC"
+}
+
 func calculateMetadata(totalRecords, page, pageSize int) FilterMetadata {
 	if totalRecords == 0 {
 		return FilterMetadata{}
@@ -72,3 +154,17 @@ func calculateMetadata(totalRecords, page, pageSize int) FilterMetadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+func calculateCursorMetadata(pageSize int, next, prev *cursor) FilterMetadata {
+	metadata := FilterMetadata{PageSize: pageSize}
+
+	if next != nil {
+		metadata.NextCursor = next.encode()
+	}
+
+	if prev != nil {
+		metadata.PrevCursor = prev.encode()
+	}
+
+	return metadata
+}
@@ -0,0 +1,303 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+
+	GenreSlugRX = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{Nd}]+(-[\p{Ll}\p{Lo}\p{Nd}]+)*$`)
+)
+
+// Genre is an entry of the managed genre taxonomy. Movies refer to genres by
+// their slug.
+type Genre struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Slug       string    `json:"slug"`
+	Name       string    `json:"name"`
+	MovieCount int64     `json:"movie_count"`
+	Version    int32     `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 50, "slug", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(genre.Slug, GenreSlugRX), "slug", "must only contain lowercase letters and digits separated by single hyphens")
+
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	query := `
+	INSERT INTO genres (slug, name)
+	VALUES ($1, $2)
+	RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, genre.Slug, genre.Name).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m GenreModel) GetBySlug(slug string) (*Genre, error) {
+	query := `
+		SELECT genres.id, genres.created_at, genres.slug, genres.name, count(movies.id), genres.version
+		FROM genres
+		LEFT JOIN movies ON movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL
+		WHERE genres.slug = $1
+		GROUP BY genres.id`
+
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Slug,
+		&genre.Name,
+		&genre.MovieCount,
+		&genre.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+// GetAll returns every genre together with the number of movies (not counting
+// those in the trash) it is assigned to, ordered by name.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT genres.id, genres.created_at, genres.slug, genres.name, count(movies.id), genres.version
+		FROM genres
+		LEFT JOIN movies ON movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL
+		GROUP BY genres.id
+		ORDER BY genres.name ASC, genres.id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreatedAt,
+			&genre.Slug,
+			&genre.Name,
+			&genre.MovieCount,
+			&genre.Version,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+// GetAllSlugs returns the slugs of all genres, which are the values movies
+// may use as their genres.
+func (m GenreModel) GetAllSlugs() ([]string, error) {
+	query := `
+		SELECT slug
+		FROM genres`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	slugs := []string{}
+
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slugs, nil
+}
+
+// Update saves the genre's new name and slug. If the slug changed, it is
+// replaced in all movies, including those in the trash, as part of the same
+// transaction, and a revision is recorded for each of them on behalf of the
+// given user.
+func (m GenreModel) Update(genre *Genre, previousSlug string, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE genres
+		SET slug = $1, name = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+
+	args := []interface{}{genre.Slug, genre.Name, genre.ID, genre.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if genre.Slug != previousSlug {
+		_, err = rewriteMovieGenres(ctx, tx, `array_replace(genres, $1, $2)`, previousSlug, genre.Slug, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Merge deletes the source genre after replacing it with the target genre in
+// all movies, including those in the trash. Movies which already have both
+// genres simply lose the source genre. It returns the number of movies which
+// were changed.
+func (m GenreModel) Merge(source, target *Genre, userID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM genres
+		WHERE id = $1 AND version = $2`
+
+	result, err := tx.ExecContext(ctx, query, source.ID, source.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if rowsAffected == 0 {
+		return 0, ErrEditConflict
+	}
+
+	// the target must not be renamed or merged itself before the movies refer to it
+	query = `
+		SELECT version
+		FROM genres
+		WHERE id = $1 AND slug = $2
+		FOR UPDATE`
+
+	var version int32
+
+	err = tx.QueryRowContext(ctx, query, target.ID, target.Slug).Scan(&version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrEditConflict
+		default:
+			return 0, err
+		}
+	}
+
+	replacement := `CASE WHEN genres @> ARRAY[$2::text] THEN array_remove(genres, $1) ELSE array_replace(genres, $1, $2) END`
+
+	count, err := rewriteMovieGenres(ctx, tx, replacement, source.Slug, target.Slug, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// rewriteMovieGenres sets the genres of every movie containing the slug $1 to
+// the result of the given expression, which may refer to the new slug as $2.
+// The movies' versions are incremented and revisions are recorded for them.
+func rewriteMovieGenres(ctx context.Context, tx *sql.Tx, expression, oldSlug, newSlug string, userID int64) (int64, error) {
+	query := `
+		WITH updated AS (
+			UPDATE movies
+			SET genres = ` + expression + `, version = version + 1
+			WHERE genres @> ARRAY[$1::text]
+			RETURNING id, version, title, year, runtime, genres
+		)
+		INSERT INTO movie_revisions (movie_id, version, operation, user_id, title, year, runtime, genres)
+		SELECT id, version, 'update', $3, title, year, runtime, genres
+		FROM updated`
+
+	result, err := tx.ExecContext(ctx, query, oldSlug, newSlug, sql.NullInt64{Int64: userID, Valid: userID > 0})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var ErrIdempotencyKeyInUse = errors.New("idempotency key in use")
+
+// IdempotencyKey is a key a client sent along with a request so that it can
+// safely retry the request. Status is 0 while the request is still being
+// processed; afterwards the response is stored to be replayed to retries.
+// User ID 0 is used for anonymous requests.
+type IdempotencyKey struct {
+	UserID      int64
+	Key         string
+	Fingerprint []byte
+	Status      int
+	Header      http.Header
+	Body        []byte
+	Expiry      time.Time
+}
+
+type IdempotencyKeyModel struct {
+	DB *sql.DB
+}
+
+// Reserve claims the key for a request with the given fingerprint until the
+// lease expires, after which the key may be claimed again unless the request
+// has been completed. It returns the expiry of the reservation, which tells it
+// apart from later ones to Complete and Release. If the key is already claimed
+// it returns the existing key instead, so its stored response can be replayed,
+// or ErrIdempotencyKeyInUse if it has just been released.
+func (m IdempotencyKeyModel) Reserve(userID int64, key string, fingerprint []byte, lease time.Duration) (time.Time, *IdempotencyKey, error) {
+	// expired keys are claimed again as if they never existed
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, expiry)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status = NULL, header = NULL, body = NULL, expiry = EXCLUDED.expiry
+		WHERE idempotency_keys.expiry < NOW()
+		RETURNING expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservedUntil time.Time
+
+	err := m.DB.QueryRowContext(ctx, query, userID, key, fingerprint, time.Now().Add(lease)).Scan(&reservedUntil)
+	if err == nil {
+		return reservedUntil, nil, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil, err
+	}
+
+	query = `
+		SELECT user_id, key, fingerprint, coalesce(status, 0), header, body, expiry
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2`
+
+	var existing IdempotencyKey
+	var header []byte
+
+	err = m.DB.QueryRowContext(ctx, query, userID, key).Scan(
+		&existing.UserID,
+		&existing.Key,
+		&existing.Fingerprint,
+		&existing.Status,
+		&header,
+		&existing.Body,
+		&existing.Expiry,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return time.Time{}, nil, ErrIdempotencyKeyInUse
+		default:
+			return time.Time{}, nil, err
+		}
+	}
+
+	if header != nil {
+		err = json.Unmarshal(header, &existing.Header)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+	}
+
+	return time.Time{}, &existing, nil
+}
+
+// Complete stores the response to the request which reserved the key until
+// reservedUntil, to be replayed until the key's expiry. Nothing is stored if
+// the reservation has been taken over by a retry since.
+func (m IdempotencyKeyModel) Complete(key *IdempotencyKey, reservedUntil time.Time) error {
+	header, err := json.Marshal(key.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status = $1, header = $2, body = $3, expiry = $4
+		WHERE user_id = $5 AND key = $6 AND status IS NULL AND expiry = $7`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, key.Status, header, key.Body, key.Expiry, key.UserID, key.Key, reservedUntil)
+	return err
+}
+
+// Release deletes the reservation of a key made until reservedUntil, so that
+// the request can be retried as if it had never been made.
+func (m IdempotencyKeyModel) Release(userID int64, key string, reservedUntil time.Time) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND status IS NULL AND expiry = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, key, reservedUntil)
+	return err
+}
+
+// DeleteExpired deletes all keys whose ttl has expired and returns how many
+// there were.
+func (m IdempotencyKeyModel) DeleteExpired() (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expiry < NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
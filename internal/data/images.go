@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	ImagePoster   = "poster"
+	ImageBackdrop = "backdrop"
+)
+
+// ImageVariants lists the resized versions stored for every uploaded image.
+var ImageVariants = []string{"small", "medium", "large"}
+
+// Image refers to an uploaded image by the "<movie id>/<token>" prefix its
+// variants are stored under. It is empty if the movie has no such image.
+type Image string
+
+// MarshalJSON encodes the image as the URLs of its variants. The token changes
+// with every upload, so these URLs can be cached indefinitely.
+func (i Image) MarshalJSON() ([]byte, error) {
+	urls := make(map[string]string, len(ImageVariants))
+	for _, variant := range ImageVariants {
+		urls[variant] = fmt.Sprintf("/v1/images/%s/%s", i, variant)
+	}
+
+	return json.Marshal(urls)
+}
+
+func (i *Image) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*i = ""
+		return nil
+	case string:
+		*i = Image(value)
+		return nil
+	case []byte:
+		*i = Image(value)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Image", src)
+	}
+}
+
+// imagesSubquery looks up the poster and backdrop of a single movie and is
+// meant to be used in a LATERAL join against the movies table.
+const imagesSubquery = `
+		SELECT max(movie_images.movie_id || '/' || movie_images.token) FILTER (WHERE movie_images.kind = 'poster') AS poster,
+			max(movie_images.movie_id || '/' || movie_images.token) FILTER (WHERE movie_images.kind = 'backdrop') AS backdrop
+		FROM movie_images
+		WHERE movie_images.movie_id = movies.id`
+
+type MovieImage struct {
+	MovieID   int64
+	Kind      string
+	Token     string
+	Width     int
+	Height    int
+	CreatedAt time.Time
+}
+
+// Image returns the reference to the image as it is included in a movie.
+func (i *MovieImage) Image() Image {
+	return Image(fmt.Sprintf("%d/%s", i.MovieID, i.Token))
+}
+
+type ImageModel struct {
+	DB *sql.DB
+}
+
+// Upsert saves the image as the movie's image of its kind and returns the
+// image it replaced, if any, so that its files can be deleted.
+func (m ImageModel) Upsert(image *MovieImage) (Image, error) {
+	query := `
+		WITH previous AS (
+			SELECT movie_id || '/' || token AS image
+			FROM movie_images
+			WHERE movie_id = $1 AND kind = $2
+			FOR UPDATE
+		)
+		INSERT INTO movie_images (movie_id, kind, token, width, height)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (movie_id, kind) DO UPDATE
+		SET token = EXCLUDED.token, width = EXCLUDED.width, height = EXCLUDED.height, created_at = NOW()
+		RETURNING created_at, (SELECT image FROM previous)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var previous Image
+
+	args := []interface{}{image.MovieID, image.Kind, image.Token, image.Width, image.Height}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&image.CreatedAt, &previous)
+	if err != nil {
+		return "", err
+	}
+
+	return previous, nil
+}
+
+// Delete removes the movie's image of the given kind and returns it, so that
+// its files can be deleted.
+func (m ImageModel) Delete(movieID int64, kind string) (Image, error) {
+	if movieID < 1 {
+		return "", ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_images
+		WHERE movie_id = $1 AND kind = $2
+		RETURNING movie_id || '/' || token`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var image Image
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, kind).Scan(&image)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return image, nil
+}
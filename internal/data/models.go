@@ -11,17 +11,41 @@ var (
 )
 
 type Models struct {
-	Movies      MovieModel
-	Users       UserModel
-	Tokens      TokenModel
-	Permissions PermissionModel
+	Movies       MovieModel
+	Users        UserModel
+	Tokens       TokenModel
+	Permissions  PermissionModel
+	Reviews      ReviewModel
+	Watchlist    WatchlistModel
+	People       PersonModel
+	Credits      CreditModel
+	Revisions    RevisionModel
+	Images       ImageModel
+	Genres       GenreModel
+	Translations TranslationModel
+	Idempotency  IdempotencyKeyModel
+	Collections  CollectionModel
+	Relations    RelationModel
+	Releases     ReleaseModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Movies:       MovieModel{DB: db},
+		Users:        UserModel{DB: db},
+		Tokens:       TokenModel{DB: db},
+		Permissions:  PermissionModel{DB: db},
+		Reviews:      ReviewModel{DB: db},
+		Watchlist:    WatchlistModel{DB: db},
+		People:       PersonModel{DB: db},
+		Credits:      CreditModel{DB: db},
+		Revisions:    RevisionModel{DB: db},
+		Images:       ImageModel{DB: db},
+		Genres:       GenreModel{DB: db},
+		Translations: TranslationModel{DB: db},
+		Idempotency:  IdempotencyKeyModel{DB: db},
+		Collections:  CollectionModel{DB: db},
+		Relations:    RelationModel{DB: db},
+		Releases:     ReleaseModel{DB: db},
 	}
 }
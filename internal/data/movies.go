@@ -3,8 +3,10 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/lib/pq"
@@ -12,16 +14,51 @@ import (
 )
 
 type Movie struct {
-	ID         int64      `json:"id"`
-	CreatedAt  time.Time  `json:"-"`
-	Title      string     `json:"title"`
-	Year       int32      `json:"year,omitempty"`
-	RuntimeMin RuntimeMin `json:"runtime,omitempty"`
-	Genres     []string   `json:"genres,omitempty"`
-	Version    int32      `json:"version"`
+	ID            int64              `json:"id"`
+	CreatedAt     time.Time          `json:"-"`
+	Title         string             `json:"title"`
+	OriginalTitle string             `json:"original_title,omitempty"`
+	Synopsis      string             `json:"synopsis,omitempty"`
+	Year          int32              `json:"year,omitempty"`
+	RuntimeMin    RuntimeMin         `json:"runtime,omitempty"`
+	Genres        []string           `json:"genres,omitempty"`
+	AverageRating float64            `json:"average_rating"`
+	RatingCount   int64              `json:"rating_count"`
+	Poster        Image              `json:"poster,omitempty"`
+	Backdrop      Image              `json:"backdrop,omitempty"`
+	ExternalIDs   ExternalIDs        `json:"external_ids,omitempty"`
+	Certification string             `json:"certification,omitempty"`
+	TitleMatch    string             `json:"title_match,omitempty"`
+	Relevance     float64            `json:"-"`
+	DeletedAt     *time.Time         `json:"deleted_at,omitempty"`
+	Credits       []*Credit          `json:"credits,omitempty"`
+	Collections   []*MovieCollection `json:"collections,omitempty"`
+	Version       int32              `json:"version"`
+	RuntimeFormat RuntimeFormat      `json:"-"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// MarshalJSON writes the movie with its runtime in the movie's RuntimeFormat.
+func (m Movie) MarshalJSON() ([]byte, error) {
+	type movie Movie
+
+	if m.RuntimeFormat == RuntimeFormatDefault || m.RuntimeMin == 0 {
+		return json.Marshal(movie(m))
+	}
+
+	runtime, err := m.RuntimeMin.MarshalJSONFormat(m.RuntimeFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		movie
+		Runtime json.RawMessage `json:"runtime"`
+	}{movie(m), runtime})
+}
+
+// ValidateMovie checks the movie's fields. Its genres must be among the given
+// slugs of the managed genres.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres []string) {
 	v.Check(movie.Title != "", "title", "must not be empty")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 
@@ -36,23 +73,299 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+
+	for _, genre := range movie.Genres {
+		if !validator.PermittedValue(genre, genres...) {
+			v.AddError("genres", fmt.Sprintf("must only contain known genres, %q is unknown", genre))
+			break
+		}
+	}
+
+	ValidateExternalIDs(v, movie.ExternalIDs)
+}
+
+// SearchConfigs lists the text search configurations a title search may use,
+// each of which is backed by its own GIN index on the movies table.
+var SearchConfigs = []string{"simple", "english", "german", "french"}
+
+// MovieCriteria narrows down the movies returned by GetAll. Zero values mean
+// that the respective criterion is not applied.
+type MovieCriteria struct {
+	Title            string
+	SearchConfig     string
+	Genres           []string
+	GenresAny        []string
+	GenresExclude    []string
+	YearMin          int
+	YearMax          int
+	RuntimeMin       int
+	RuntimeMax       int
+	RelatedTo        int64
+	Relation         string
+	Country          string
+	ReleasedAfter    Date
+	MaxCertification string
+}
+
+func ValidateMovieCriteria(v *validator.Validator, c MovieCriteria) {
+	v.Check(validator.PermittedValue(c.SearchConfig, SearchConfigs...), "search_config", "invalid search configuration")
+
+	v.Check(c.YearMin == 0 || c.YearMin >= 1888, "year_min", "must be greater than 1888")
+	v.Check(c.YearMax == 0 || c.YearMax >= 1888, "year_max", "must be greater than 1888")
+	v.Check(c.YearMin == 0 || c.YearMax == 0 || c.YearMin <= c.YearMax, "year_min", "must not be greater than year_max")
+
+	v.Check(c.RuntimeMin >= 0, "runtime_min", "must not be negative")
+	v.Check(c.RuntimeMax >= 0, "runtime_max", "must not be negative")
+	v.Check(c.RuntimeMin == 0 || c.RuntimeMax == 0 || c.RuntimeMin <= c.RuntimeMax, "runtime_min", "must not be greater than runtime_max")
+
+	v.Check(len(c.Genres) <= 5, "genres", "must not contain more than 5 genres")
+	v.Check(len(c.GenresAny) <= 20, "genres_any", "must not contain more than 20 genres")
+	v.Check(len(c.GenresExclude) <= 20, "genres_exclude", "must not contain more than 20 genres")
+
+	v.Check(c.RelatedTo >= 0, "related_to", "must be a positive integer")
+	v.Check(c.Relation == "" || validator.PermittedValue(c.Relation, RelationTypes...), "relation", "must be one of sequel_of, remake_of, based_on or spin_off")
+	v.Check(c.Relation == "" || c.RelatedTo > 0, "relation", "must only be provided together with related_to")
+
+	if c.Country != "" {
+		ValidateCountry(v, "country", c.Country)
+	}
+
+	v.Check(c.MaxCertification == "" || c.Country != "", "max_certification", "must only be provided together with country")
+	v.Check(c.MaxCertification == "" || c.Country == "" || CertificationsUpTo(c.Country, c.MaxCertification) != nil, "max_certification", "must be a valid certification in this country")
+}
+
+// where translates the criteria into conditions on the movies table which can
+// be served by the title, genres, year and runtime indexes and the indexes of
+// the relations and releases tables.
+func (c MovieCriteria) where() *whereClause {
+	where := &whereClause{}
+	where.add("deleted_at IS NULL")
+
+	if c.Title != "" {
+		// the configuration is taken from SearchConfigs, so it is safe to interpolate
+		// and keeps the expression identical to the one in the index
+		// translated titles are looked up independently of the movie, so both
+		// conditions can be answered by an index
+		where.add(fmt.Sprintf(`(to_tsvector('%[1]s', title) @@ websearch_to_tsquery('%[1]s', $%%[1]d) OR id = ANY(ARRAY(
+			SELECT movie_translations.movie_id FROM movie_translations
+			WHERE to_tsvector('%[1]s', movie_translations.title) @@ websearch_to_tsquery('%[1]s', $%%[1]d))))`, c.searchConfig()), c.Title)
+	}
+
+	if len(c.Genres) > 0 {
+		where.add("genres @> $%d", pq.Array(c.Genres))
+	}
+
+	if len(c.GenresAny) > 0 {
+		where.add("genres && $%d", pq.Array(c.GenresAny))
+	}
+
+	if len(c.GenresExclude) > 0 {
+		where.add("NOT (genres && $%d)", pq.Array(c.GenresExclude))
+	}
+
+	if c.YearMin > 0 {
+		where.add("year >= $%d", c.YearMin)
+	}
+
+	if c.YearMax > 0 {
+		where.add("year <= $%d", c.YearMax)
+	}
+
+	if c.RuntimeMin > 0 {
+		where.add("runtime >= $%d", c.RuntimeMin)
+	}
+
+	if c.RuntimeMax > 0 {
+		where.add("runtime <= $%d", c.RuntimeMax)
+	}
+
+	// relations count in both directions, e.g. a remake and its original are related to each other
+	if c.RelatedTo > 0 && c.Relation != "" {
+		where.add(`id IN (
+			SELECT movie_id FROM movie_relations WHERE related_movie_id = $%[1]d AND type = $%[2]d
+			UNION SELECT related_movie_id FROM movie_relations WHERE movie_id = $%[1]d AND type = $%[2]d)`, c.RelatedTo, c.Relation)
+	} else if c.RelatedTo > 0 {
+		where.add(`id IN (
+			SELECT movie_id FROM movie_relations WHERE related_movie_id = $%[1]d
+			UNION SELECT related_movie_id FROM movie_relations WHERE movie_id = $%[1]d)`, c.RelatedTo)
+	}
+
+	if c.Country != "" {
+		where.add("id IN (SELECT movie_id FROM movie_releases WHERE country = $%d)", c.Country)
+	}
+
+	// the release date and the certification may come from different releases,
+	// e.g. the certification of the earliest certified release and the date of the digital one
+	if !c.ReleasedAfter.IsZero() && c.Country != "" {
+		where.add("id IN (SELECT movie_id FROM movie_releases WHERE country = $%d AND release_date > $%d)", c.Country, c.ReleasedAfter)
+	} else if !c.ReleasedAfter.IsZero() {
+		where.add("id IN (SELECT movie_id FROM movie_releases WHERE release_date > $%d)", c.ReleasedAfter)
+	}
+
+	if c.MaxCertification != "" {
+		where.add(fmt.Sprintf(certificationExpression, "movies.id", "$%d")+" = ANY($%d)",
+			c.Country, pq.Array(CertificationsUpTo(c.Country, c.MaxCertification)))
+	}
+
+	return where
 }
 
+func (c MovieCriteria) searchConfig() string {
+	for _, config := range SearchConfigs {
+		if c.SearchConfig == config {
+			return config
+		}
+	}
+
+	return "simple"
+}
+
+// searchSubquery ranks a movie against the title search and highlights the
+// matching words. It is meant to be used in a LATERAL join against the movies table.
+func (c MovieCriteria) searchSubquery(where *whereClause) string {
+	if c.Title == "" {
+		return "SELECT 0::real AS relevance, ''::text AS title_match"
+	}
+
+	return fmt.Sprintf(`
+		SELECT greatest(ts_rank(to_tsvector('%[1]s', movies.title), websearch_to_tsquery('%[1]s', $%[2]d)), (
+				SELECT coalesce(max(ts_rank(to_tsvector('%[1]s', movie_translations.title), websearch_to_tsquery('%[1]s', $%[2]d))), 0)
+				FROM movie_translations
+				WHERE movie_translations.movie_id = movies.id
+			))::real AS relevance,
+			ts_headline('%[1]s', movies.title, websearch_to_tsquery('%[1]s', $%[2]d)) AS title_match`,
+		c.searchConfig(), where.bind(c.Title))
+}
+
+// ratingsSubquery aggregates the reviews of a single movie and is meant to be
+// used in a LATERAL join against the movies table.
+const ratingsSubquery = `
+		SELECT COALESCE(ROUND(AVG(reviews.rating), 2), 0) AS rating, count(reviews.id) AS rating_count
+		FROM reviews
+		WHERE reviews.movie_id = movies.id`
+
 type MovieModel struct {
 	DB *sql.DB
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+// Insert creates the movie and records its first revision on behalf of the given user.
+func (m MovieModel) Insert(movie *Movie, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.insert(ctx, tx, movie, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertBatch creates all movies within a single transaction, skipping those
+// which are probably duplicates of an existing movie, as matched by
+// duplicateCondition. The returned slice tells for each movie whether it has
+// been created.
+func (m MovieModel) InsertBatch(movies []*Movie, userID int64) ([]bool, error) {
+	created, _, err := m.insertBatch(movies, userID, false)
+	return created, err
+}
+
+// InsertEach works like InsertBatch, except that a movie which fails to be
+// created is rolled back on its own while the others are still created. Its
+// error is returned at the movie's index.
+func (m MovieModel) InsertEach(movies []*Movie, userID int64) ([]bool, []error, error) {
+	return m.insertBatch(movies, userID, true)
+}
+
+// batchTimeout allows for a few statements per movie on top of a fixed base,
+// as batches hold up to thousands of movies.
+func batchTimeout(size int) time.Duration {
+	return 10*time.Second + time.Duration(size)*10*time.Millisecond
+}
+
+func (m MovieModel) insertBatch(movies []*Movie, userID int64, savepoints bool) ([]bool, []error, error) {
+	query := fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT 1 FROM movies
+			WHERE %s
+		)`, fmt.Sprintf(duplicateCondition, "$1", "$2"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout(len(movies)))
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	created := make([]bool, len(movies))
+	errs := make([]error, len(movies))
+
+	insert := func(movie *Movie) (bool, error) {
+		var exists bool
+		err := tx.QueryRowContext(ctx, query, movie.Title, movie.Year).Scan(&exists)
+		if err != nil || exists {
+			return false, err
+		}
+
+		return true, m.insert(ctx, tx, movie, userID)
+	}
+
+	for i, movie := range movies {
+		if !savepoints {
+			created[i], err = insert(movie)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, "SAVEPOINT insert_movie")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		created[i], errs[i] = insert(movie)
+		if errs[i] != nil {
+			created[i] = false
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT insert_movie")
+		} else {
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT insert_movie")
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return created, errs, tx.Commit()
+}
+
+func (m MovieModel) insert(ctx context.Context, tx *sql.Tx, movie *Movie, userID int64) error {
 	query := `
 	INSERT INTO movies (title, year, runtime, genres)
 	VALUES ($1, $2, $3, $4)
 	RETURNING id, created_at, version`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
 	args := []interface{}{movie.Title, movie.Year, movie.RuntimeMin, pq.Array(movie.Genres)}
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	err = setExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, movie, RevisionInsert, userID)
 }
 
 func (m MovieModel) Get(id int64) (*Movie, error) {
@@ -61,9 +374,12 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	}
 
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
+		SELECT id, created_at, title, year, runtime, genres, ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids, version
 		FROM movies
-		WHERE id = $1`
+		LEFT JOIN LATERAL (` + ratingsSubquery + `) ratings ON true
+		LEFT JOIN LATERAL (` + imagesSubquery + `) images ON true
+		LEFT JOIN LATERAL (` + externalIDsSubquery + `) external_ids ON true
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var movie Movie
 
@@ -77,6 +393,11 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Year,
 		&movie.RuntimeMin,
 		pq.Array(&movie.Genres),
+		&movie.AverageRating,
+		&movie.RatingCount,
+		&movie.Poster,
+		&movie.Backdrop,
+		&movie.ExternalIDs,
 		&movie.Version,
 	)
 
@@ -92,11 +413,38 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+// GetByExternalID returns the movie which has the given ID in an external dataset.
+func (m MovieModel) GetByExternalID(source, externalID string) (*Movie, error) {
+	query := `
+		SELECT movie_id
+		FROM movie_external_ids
+		WHERE source = $1 AND external_id = $2`
+
+	var id int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, source, externalID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(id)
+}
+
+// Update saves the movie if it hasn't been changed since it was read and
+// records the new version as a revision on behalf of the given user.
+func (m MovieModel) Update(movie *Movie, userID int64) error {
 	query := `
 		UPDATE movies
 		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 		RETURNING version`
 
 	args := []interface{}{
@@ -111,7 +459,13 @@ func (m MovieModel) Update(movie *Movie) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -121,18 +475,133 @@ func (m MovieModel) Update(movie *Movie) error {
 		}
 	}
 
-	return nil
+	err = setExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, movie, RevisionUpdate, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete moves a movie to the trash. It stays hidden from Get and GetAll until
+// it is either restored or purged.
+func (m MovieModel) Delete(id int64, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, title, year, runtime, genres, version`
+
+	return m.changeTrashState(query, id, RevisionDelete, userID)
 }
 
-func (m MovieModel) Delete(id int64) error {
+func (m MovieModel) Restore(id int64, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, title, year, runtime, genres, version`
+
+	return m.changeTrashState(query, id, RevisionRestore, userID)
+}
+
+func (m MovieModel) changeTrashState(query string, id int64, operation string, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var movie Movie
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&movie.ID,
+		&movie.Title,
+		&movie.Year,
+		&movie.RuntimeMin,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = insertRevision(ctx, tx, &movie, operation, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m MovieModel) Purge(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
 		DELETE FROM movies
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	return m.execForID(query, id)
+}
+
+// PurgeDeletedBefore permanently deletes all movies which were moved to the
+// trash before the given time and returns their ids.
+func (m MovieModel) PurgeDeletedBefore(cutoff time.Time) ([]int64, error) {
+	query := `
+		DELETE FROM movies
+		WHERE deleted_at < $1
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	ids := []int64{}
 
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (m MovieModel) execForID(query string, id int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -153,18 +622,76 @@ func (m MovieModel) Delete(id int64) error {
 	return nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, FilterMetadata, error) {
+func (m MovieModel) GetAllDeleted(filters Filters) ([]*Movie, FilterMetadata, error) {
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, deleted_at, version
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '') 
-		AND (genres @> $2 OR $2 = '{}')
+		WHERE deleted_at IS NOT NULL
 		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	defer rows.Close()
+	movies := []*Movie{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.RuntimeMin,
+			pq.Array(&movie.Genres),
+			&movie.DeletedAt,
+			&movie.Version,
+		)
+
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+func (m MovieModel) GetAll(criteria MovieCriteria, filters Filters) ([]*Movie, FilterMetadata, error) {
+	if filters.CursorMode {
+		return m.getAllByCursor(criteria, filters)
+	}
+
+	where := criteria.where()
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids,
+			search.relevance, search.title_match, version
+		FROM movies
+		LEFT JOIN LATERAL (`+ratingsSubquery+`) ratings ON true
+		LEFT JOIN LATERAL (`+imagesSubquery+`) images ON true
+		LEFT JOIN LATERAL (`+externalIDsSubquery+`) external_ids ON true
+		LEFT JOIN LATERAL (%s) search ON true
+		WHERE %s
+		ORDER BY %s %s, id ASC
+		LIMIT $%d OFFSET $%d`, criteria.searchSubquery(where), where.String(), filters.sortColumn(), filters.sortDirection(), where.next(), where.next()+1)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := append(where.args, filters.limit(), filters.offset())
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, FilterMetadata{}, err
@@ -184,6 +711,13 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&movie.Year,
 			&movie.RuntimeMin,
 			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Poster,
+			&movie.Backdrop,
+			&movie.ExternalIDs,
+			&movie.Relevance,
+			&movie.TitleMatch,
 			&movie.Version,
 		)
 
@@ -201,3 +735,294 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	return movies, metadata, nil
 }
+
+// getAllByCursor pages through the movies using the sort column and id as the
+// key instead of an offset, so deep pages stay cheap and stable while rows change.
+func (m MovieModel) getAllByCursor(criteria MovieCriteria, filters Filters) ([]*Movie, FilterMetadata, error) {
+	var current cursor
+	if filters.Cursor != "" {
+		var err error
+		current, err = decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+	}
+
+	where := criteria.where()
+	operator, direction := filters.keyset(current.Backward)
+
+	// unlike in offset mode, ties are broken by id in the sort direction, as the
+	// keyset condition compares the sort column and id as a single row value
+	if filters.Cursor != "" {
+		where.add(fmt.Sprintf("(%s, id) %s ($%%d, $%%d)", filters.sortColumn(), operator), current.Value, current.ID)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, created_at, title, year, runtime, genres, ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids,
+			search.relevance, search.title_match, version
+		FROM movies
+		LEFT JOIN LATERAL (`+ratingsSubquery+`) ratings ON true
+		LEFT JOIN LATERAL (`+imagesSubquery+`) images ON true
+		LEFT JOIN LATERAL (`+externalIDsSubquery+`) external_ids ON true
+		LEFT JOIN LATERAL (%s) search ON true
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT $%d`, criteria.searchSubquery(where), where.String(), filters.sortColumn(), direction, direction, where.next())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// fetch one additional row to find out whether there is another page
+	args := append(where.args, filters.limit()+1)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	defer rows.Close()
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.RuntimeMin,
+			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Poster,
+			&movie.Backdrop,
+			&movie.ExternalIDs,
+			&movie.Relevance,
+			&movie.TitleMatch,
+			&movie.Version,
+		)
+
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	hasMore := len(movies) > filters.limit()
+	if hasMore {
+		movies = movies[:filters.limit()]
+	}
+
+	if current.Backward {
+		for i, j := 0, len(movies)-1; i < j; i, j = i+1, j-1 {
+			movies[i], movies[j] = movies[j], movies[i]
+		}
+	}
+
+	var next, prev *cursor
+	if len(movies) > 0 {
+		first, last := movies[0], movies[len(movies)-1]
+
+		if current.Backward || hasMore {
+			next = &cursor{Sort: filters.Sort, Value: movieSortValue(last, filters.sortColumn()), ID: last.ID}
+		}
+
+		if (!current.Backward && filters.Cursor != "") || (current.Backward && hasMore) {
+			prev = &cursor{Sort: filters.Sort, Value: movieSortValue(first, filters.sortColumn()), ID: first.ID, Backward: true}
+		}
+	}
+
+	return movies, calculateCursorMetadata(filters.PageSize, next, prev), nil
+}
+
+// Export calls fn for every movie matching the criteria in the order given by
+// filters.Sort. Rows are read from the database one at a time rather than
+// collected into a slice, so arbitrarily large catalogues can be streamed. The
+// context bounds the whole export instead of a fixed timeout.
+func (m MovieModel) Export(ctx context.Context, criteria MovieCriteria, filters Filters, fn func(*Movie) error) error {
+	where := criteria.where()
+	query := fmt.Sprintf(`
+		SELECT id, created_at, title, year, runtime, genres, ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids,
+			search.relevance, search.title_match, version
+		FROM movies
+		LEFT JOIN LATERAL (`+ratingsSubquery+`) ratings ON true
+		LEFT JOIN LATERAL (`+imagesSubquery+`) images ON true
+		LEFT JOIN LATERAL (`+externalIDsSubquery+`) external_ids ON true
+		LEFT JOIN LATERAL (%s) search ON true
+		WHERE %s
+		ORDER BY %s %s, id ASC`, criteria.searchSubquery(where), where.String(), filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.RuntimeMin,
+			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Poster,
+			&movie.Backdrop,
+			&movie.ExternalIDs,
+			&movie.Relevance,
+			&movie.TitleMatch,
+			&movie.Version,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(&movie)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type TitleSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+// Autocomplete suggests titles which resemble the (partially typed) query,
+// tolerating typos through trigram word similarity.
+func (m MovieModel) Autocomplete(q string, limit int) ([]*TitleSuggestion, error) {
+	query := `
+		SELECT id, title, year
+		FROM movies
+		WHERE lower($1) <% lower(title) AND deleted_at IS NULL
+		ORDER BY word_similarity(lower($1), lower(title)) DESC, id ASC
+		LIMIT $2`
+
+	// this is called on every keystroke, so give up early rather than pile up requests
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	suggestions := []*TitleSuggestion{}
+
+	for rows.Next() {
+		var suggestion TitleSuggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// GetSimilar ranks the movies sharing at least one genre with the given movie,
+// which the genres index can look up, by a weighted score of their genre
+// overlap (Jaccard index), how close their years are and how many users gave
+// both movies a rating of 7 or more.
+func (m MovieModel) GetSimilar(source *Movie, limit int) ([]*Movie, error) {
+	query := `
+		SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+			ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids, movies.version
+		FROM movies
+		LEFT JOIN LATERAL (` + ratingsSubquery + `) ratings ON true
+		LEFT JOIN LATERAL (` + imagesSubquery + `) images ON true
+		LEFT JOIN LATERAL (` + externalIDsSubquery + `) external_ids ON true
+		LEFT JOIN LATERAL (
+			SELECT cardinality(ARRAY(SELECT unnest(movies.genres) INTERSECT SELECT unnest($2::text[])))::float8 /
+				cardinality(ARRAY(SELECT unnest(movies.genres) UNION SELECT unnest($2::text[]))) AS genres,
+				1 / (1 + abs(movies.year - $3) / 10.0) AS year,
+				(
+					SELECT count(*)
+					FROM reviews
+					INNER JOIN reviews source_reviews ON source_reviews.user_id = reviews.user_id
+					WHERE reviews.movie_id = movies.id AND reviews.rating >= 7
+						AND source_reviews.movie_id = $1 AND source_reviews.rating >= 7
+				) AS shared_ratings
+		) similarity ON true
+		WHERE movies.genres && $2 AND movies.id <> $1 AND movies.deleted_at IS NULL
+		ORDER BY 0.6 * similarity.genres + 0.25 * similarity.year +
+			0.15 * similarity.shared_ratings / (similarity.shared_ratings + 5.0) DESC, movies.id ASC
+		LIMIT $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, source.ID, pq.Array(source.Genres), source.Year, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.RuntimeMin,
+			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Poster,
+			&movie.Backdrop,
+			&movie.ExternalIDs,
+			&movie.Version,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+func movieSortValue(movie *Movie, column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		return strconv.FormatInt(int64(movie.RuntimeMin), 10)
+	case "rating":
+		return strconv.FormatFloat(movie.AverageRating, 'f', -1, 64)
+	case "relevance":
+		// relevance is cast to real in searchSubquery, so formatting it as a
+		// float32 keeps the value exact for the cursor's comparison
+		return strconv.FormatFloat(movie.Relevance, 'f', -1, 32)
+	default:
+		return strconv.FormatInt(movie.ID, 10)
+	}
+}
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+const (
+	RelationSequelOf = "sequel_of"
+	RelationRemakeOf = "remake_of"
+	RelationBasedOn  = "based_on"
+	RelationSpinOff  = "spin_off"
+)
+
+// RelationTypes lists the types a relation may have. Relations are directed:
+// the movie is the sequel, remake, adaptation or spin-off of the related movie.
+var RelationTypes = []string{RelationSequelOf, RelationRemakeOf, RelationBasedOn, RelationSpinOff}
+
+var (
+	ErrDuplicateRelation = errors.New("duplicate relation")
+)
+
+type Relation struct {
+	ID                int64     `json:"id"`
+	MovieID           int64     `json:"movie_id"`
+	MovieTitle        string    `json:"movie_title"`
+	Type              string    `json:"type"`
+	RelatedMovieID    int64     `json:"related_movie_id"`
+	RelatedMovieTitle string    `json:"related_movie_title"`
+	CreatedAt         time.Time `json:"-"`
+}
+
+func ValidateRelation(v *validator.Validator, relation *Relation) {
+	v.Check(relation.Type != "", "type", "must be provided")
+	v.Check(validator.PermittedValue(relation.Type, RelationTypes...), "type", "must be one of sequel_of, remake_of, based_on or spin_off")
+
+	v.Check(relation.RelatedMovieID > 0, "related_movie_id", "must be provided")
+	v.Check(relation.RelatedMovieID != relation.MovieID, "related_movie_id", "must not be the movie itself")
+}
+
+type RelationModel struct {
+	DB *sql.DB
+}
+
+func (m RelationModel) Insert(relation *Relation) error {
+	query := `
+	INSERT INTO movie_relations (movie_id, type, related_movie_id)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, (SELECT title FROM movies WHERE id = $1), (SELECT title FROM movies WHERE id = $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{relation.MovieID, relation.Type, relation.RelatedMovieID}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&relation.ID, &relation.CreatedAt, &relation.MovieTitle, &relation.RelatedMovieTitle)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_relations_movie_id_type_related_movie_id_key"`:
+			return ErrDuplicateRelation
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a relation of the movie, in whichever direction.
+func (m RelationModel) Delete(movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_relations
+		WHERE id = $2 AND (movie_id = $1 OR related_movie_id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForMovie returns the relations from and to the movie, skipping those
+// to movies in the trash.
+func (m RelationModel) GetAllForMovie(movieID int64) ([]*Relation, error) {
+	query := `
+		SELECT movie_relations.id, movies.id, movies.title, movie_relations.type,
+			related_movies.id, related_movies.title, movie_relations.created_at
+		FROM movie_relations
+		INNER JOIN movies ON movies.id = movie_relations.movie_id
+		INNER JOIN movies related_movies ON related_movies.id = movie_relations.related_movie_id
+		WHERE (movie_relations.movie_id = $1 OR movie_relations.related_movie_id = $1)
+			AND movies.deleted_at IS NULL AND related_movies.deleted_at IS NULL
+		ORDER BY movie_relations.type, movie_relations.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	relations := []*Relation{}
+
+	for rows.Next() {
+		var relation Relation
+		err := rows.Scan(
+			&relation.ID,
+			&relation.MovieID,
+			&relation.MovieTitle,
+			&relation.Type,
+			&relation.RelatedMovieID,
+			&relation.RelatedMovieTitle,
+			&relation.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, &relation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return relations, nil
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mwettste/greenlight/internal/validator"
+	"golang.org/x/text/language"
+)
+
+const (
+	ReleaseTheatrical = "theatrical"
+	ReleaseDigital    = "digital"
+	ReleasePhysical   = "physical"
+)
+
+// Certifications lists the age certifications of each country which has a
+// rating system we support, from the least to the most restrictive.
+var Certifications = map[string][]string{
+	"AT": {"0", "6", "10", "12", "14", "16", "18"},
+	"CH": {"0", "6", "12", "14", "16", "18"},
+	"DE": {"0", "6", "12", "16", "18"},
+	"FR": {"U", "10", "12", "16", "18"},
+	"GB": {"U", "PG", "12A", "12", "15", "18"},
+	"US": {"G", "PG", "PG-13", "R", "NC-17"},
+}
+
+// certificationExpression selects the certification a movie received in a
+// country, which is the one of its earliest certified release there. It is
+// formatted with the SQL expressions for the movie's ID and the country, and
+// shared by Certify and the max_certification filter so that both agree.
+const certificationExpression = `(
+	SELECT movie_releases.certification
+	FROM movie_releases
+	WHERE movie_releases.movie_id = %s AND movie_releases.country = %s AND movie_releases.certification <> ''
+	ORDER BY movie_releases.release_date, movie_releases.id
+	LIMIT 1)`
+
+var (
+	ErrDuplicateRelease = errors.New("duplicate release")
+)
+
+// Release is the release of a movie in a country, as an ISO 3166-1 alpha-2
+// code, together with the age certification it received there, if any.
+type Release struct {
+	ID            int64  `json:"id"`
+	MovieID       int64  `json:"-"`
+	Country       string `json:"country"`
+	Type          string `json:"type"`
+	Date          Date   `json:"date"`
+	Certification string `json:"certification,omitempty"`
+}
+
+func ValidateCountry(v *validator.Validator, key, country string) {
+	region, err := language.ParseRegion(country)
+	v.Check(err == nil && region.IsCountry() && region.String() == country, key, "must be an uppercase ISO 3166-1 alpha-2 country code")
+}
+
+func ValidateRelease(v *validator.Validator, release *Release) {
+	v.Check(release.Country != "", "country", "must be provided")
+	ValidateCountry(v, "country", release.Country)
+
+	v.Check(release.Type != "", "type", "must be provided")
+	v.Check(validator.PermittedValue(release.Type, ReleaseTheatrical, ReleaseDigital, ReleasePhysical), "type", "must be one of theatrical, digital or physical")
+
+	v.Check(!release.Date.IsZero(), "date", "must be provided")
+	v.Check(release.Date.Year() >= 1888, "date", "must not be before 1888")
+
+	if release.Certification != "" {
+		certifications, ok := Certifications[release.Country]
+		v.Check(ok, "certification", "must not be provided for this country")
+		v.Check(!ok || validator.PermittedValue(release.Certification, certifications...), "certification", "must be a valid certification in this country")
+	}
+}
+
+// CertificationsUpTo returns the certifications of the country which are at
+// most as restrictive as the given one, or nil if the country doesn't use it.
+func CertificationsUpTo(country, max string) []string {
+	certifications := Certifications[country]
+
+	for i, certification := range certifications {
+		if certification == max {
+			return certifications[:i+1]
+		}
+	}
+
+	return nil
+}
+
+type ReleaseModel struct {
+	DB *sql.DB
+}
+
+func (m ReleaseModel) Insert(release *Release) error {
+	query := `
+	INSERT INTO movie_releases (movie_id, country, type, release_date, certification)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{release.MovieID, release.Country, release.Type, release.Date, release.Certification}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&release.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_releases_movie_id_country_type_key"`:
+			return ErrDuplicateRelease
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ReleaseModel) Delete(movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_releases
+		WHERE movie_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m ReleaseModel) GetAllForMovie(movieID int64) ([]*Release, error) {
+	query := `
+		SELECT id, movie_id, country, type, release_date, certification
+		FROM movie_releases
+		WHERE movie_id = $1
+		ORDER BY country, release_date, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	releases := []*Release{}
+
+	for rows.Next() {
+		var release Release
+		err := rows.Scan(
+			&release.ID,
+			&release.MovieID,
+			&release.Country,
+			&release.Type,
+			&release.Date,
+			&release.Certification,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, &release)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// Certify sets the certification of the movies to the one they received in
+// the country, as selected by certificationExpression.
+func (m ReleaseModel) Certify(movies []*Movie, country string) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, coalesce(%s, '')
+		FROM movies
+		WHERE id = ANY($1)`, fmt.Sprintf(certificationExpression, "movies.id", "$2"))
+
+	byID := make(map[int64]*Movie, len(movies))
+	ids := make([]int64, len(movies))
+
+	for i, movie := range movies {
+		byID[movie.ID] = movie
+		ids[i] = movie.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), country)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var movieID int64
+		var certification string
+
+		if err := rows.Scan(&movieID, &certification); err != nil {
+			return err
+		}
+
+		byID[movieID].Certification = certification
+	}
+
+	return rows.Err()
+}
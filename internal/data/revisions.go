@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// MovieRevision is a snapshot of a movie taken whenever it was changed,
+// together with the user who made the change.
+type MovieRevision struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	MovieID    int64      `json:"movie_id"`
+	Version    int32      `json:"version"`
+	Operation  string     `json:"operation"`
+	UserID     *int64     `json:"user_id,omitempty"`
+	Title      string     `json:"title"`
+	Year       int32      `json:"year"`
+	RuntimeMin RuntimeMin `json:"runtime"`
+	Genres     []string   `json:"genres"`
+
+	RuntimeFormat RuntimeFormat `json:"-"`
+}
+
+// MarshalJSON writes the revision with its runtime in the revision's
+// RuntimeFormat.
+func (r MovieRevision) MarshalJSON() ([]byte, error) {
+	type revision MovieRevision
+
+	if r.RuntimeFormat == RuntimeFormatDefault {
+		return json.Marshal(revision(r))
+	}
+
+	runtime, err := r.RuntimeMin.MarshalJSONFormat(r.RuntimeFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		revision
+		Runtime json.RawMessage `json:"runtime"`
+	}{revision(r), runtime})
+}
+
+// insertRevision records the current state of the movie as part of the
+// transaction which changed it. A userID of 0 is stored as unknown.
+func insertRevision(ctx context.Context, tx *sql.Tx, movie *Movie, operation string, userID int64) error {
+	query := `
+	INSERT INTO movie_revisions (movie_id, version, operation, user_id, title, year, runtime, genres)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	args := []interface{}{
+		movie.ID,
+		movie.Version,
+		operation,
+		sql.NullInt64{Int64: userID, Valid: userID > 0},
+		movie.Title,
+		movie.Year,
+		movie.RuntimeMin,
+		pq.Array(movie.Genres),
+	}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+// GetVersion returns the snapshot of the movie as it was saved with the given
+// version. Deletes and restores don't create new versions and are skipped.
+func (m RevisionModel) GetVersion(movieID int64, version int32) (*MovieRevision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, movie_id, version, operation, user_id, title, year, runtime, genres
+		FROM movie_revisions
+		WHERE movie_id = $1 AND version = $2 AND operation IN ('insert', 'update')`
+
+	var revision MovieRevision
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, version).Scan(
+		&revision.ID,
+		&revision.CreatedAt,
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Operation,
+		&revision.UserID,
+		&revision.Title,
+		&revision.Year,
+		&revision.RuntimeMin,
+		pq.Array(&revision.Genres),
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+func (m RevisionModel) GetAllForMovie(movieID int64, filters Filters) ([]*MovieRevision, FilterMetadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, movie_id, version, operation, user_id, title, year, runtime, genres
+		FROM movie_revisions
+		WHERE movie_id = $1
+		ORDER BY %s %s, id %[2]s
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	defer rows.Close()
+	revisions := []*MovieRevision{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var revision MovieRevision
+		err := rows.Scan(
+			&totalRecords,
+			&revision.ID,
+			&revision.CreatedAt,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Operation,
+			&revision.UserID,
+			&revision.Title,
+			&revision.Year,
+			&revision.RuntimeMin,
+			pq.Array(&revision.Genres),
+		)
+
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
@@ -3,36 +3,129 @@ package data
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
-	"strings"
 )
 
 type RuntimeMin int32
 
+// RuntimeFormat selects how runtimes are written to JSON. The zero value is
+// the original "<n> mins" format.
+type RuntimeFormat string
+
+const (
+	RuntimeFormatDefault RuntimeFormat = ""
+	RuntimeFormatISO8601 RuntimeFormat = "iso8601"
+	RuntimeFormatMinutes RuntimeFormat = "minutes"
+	RuntimeFormatHuman   RuntimeFormat = "human"
+)
+
+// RuntimeFormats lists the formats clients may ask for besides the default.
+var RuntimeFormats = []RuntimeFormat{RuntimeFormatISO8601, RuntimeFormatMinutes, RuntimeFormatHuman}
+
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+var (
+	// humanRuntimeRX matches "1h 47m" and its variations, including "107 mins"
+	humanRuntimeRX   = regexp.MustCompile(`^(?:(\d+)\s*h)?\s*(?:(\d+)\s*(?:m|mins?))?$`)
+	iso8601RuntimeRX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?$`)
+)
+
 func (r RuntimeMin) MarshalJSON() ([]byte, error) {
-	jsonValue := fmt.Sprintf("%d mins", r)
-	quotedJSONValue := strconv.Quote(jsonValue)
-	return []byte(quotedJSONValue), nil
+	return r.MarshalJSONFormat(RuntimeFormatDefault)
 }
 
+// MarshalJSONFormat writes the runtime in the given format, e.g. as
+// "107 mins", "PT1H47M", 107 or "1h 47m".
+func (r RuntimeMin) MarshalJSONFormat(format RuntimeFormat) ([]byte, error) {
+	hours, minutes := r/60, r%60
+
+	var jsonValue string
+
+	switch format {
+	case RuntimeFormatMinutes:
+		return []byte(strconv.FormatInt(int64(r), 10)), nil
+	case RuntimeFormatISO8601:
+		jsonValue = "PT"
+		if hours > 0 {
+			jsonValue += fmt.Sprintf("%dH", hours)
+		}
+		if minutes > 0 || hours == 0 {
+			jsonValue += fmt.Sprintf("%dM", minutes)
+		}
+	case RuntimeFormatHuman:
+		switch {
+		case hours > 0 && minutes > 0:
+			jsonValue = fmt.Sprintf("%dh %dm", hours, minutes)
+		case hours > 0:
+			jsonValue = fmt.Sprintf("%dh", hours)
+		default:
+			jsonValue = fmt.Sprintf("%dm", minutes)
+		}
+	default:
+		jsonValue = fmt.Sprintf("%d mins", r)
+	}
+
+	return []byte(strconv.Quote(jsonValue)), nil
+}
+
+// UnmarshalJSON accepts a runtime as a number of minutes or as a string in
+// any of the formats understood by ParseRuntime.
 func (r *RuntimeMin) UnmarshalJSON(data []byte) error {
-	unquotedJSONValue, err := strconv.Unquote(string(data))
-	if err != nil {
-		return ErrInvalidRuntimeFormat
+	if minutes, err := strconv.ParseInt(string(data), 10, 32); err == nil {
+		*r = RuntimeMin(minutes)
+		return nil
 	}
 
-	splitted := strings.Split(unquotedJSONValue, " ")
-	if len(splitted) != 2 || splitted[1] != "mins" {
+	unquotedJSONValue, err := strconv.Unquote(string(data))
+	if err != nil {
 		return ErrInvalidRuntimeFormat
 	}
 
-	runtimeInMin, err := strconv.ParseInt(splitted[0], 10, 32)
+	runtime, err := ParseRuntime(unquotedJSONValue)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	*r = RuntimeMin(runtimeInMin)
+	*r = runtime
 	return nil
 }
+
+// ParseRuntime parses a runtime given as plain minutes ("107"), as "<n> mins",
+// in hours and minutes ("1h 47m") or as an ISO 8601 duration ("PT1H47M").
+func ParseRuntime(s string) (RuntimeMin, error) {
+	if minutes, err := strconv.ParseInt(s, 10, 32); err == nil {
+		return RuntimeMin(minutes), nil
+	}
+
+	match := iso8601RuntimeRX.FindStringSubmatch(s)
+	if match == nil {
+		match = humanRuntimeRX.FindStringSubmatch(s)
+	}
+
+	if match == nil || (match[1] == "" && match[2] == "") {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	var total int64
+
+	for i, factor := range []int64{60, 1} {
+		if match[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(match[i+1], 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+
+		total += n * factor
+	}
+
+	if total > math.MaxInt32 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return RuntimeMin(total), nil
+}
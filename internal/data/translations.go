@@ -0,0 +1,174 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+// MovieTranslation is an alternate title, and optionally a synopsis, of a
+// movie in the language identified by a canonical BCP 47 tag like "de-CH".
+type MovieTranslation struct {
+	MovieID   int64     `json:"-"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title"`
+	Synopsis  string    `json:"synopsis,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ValidateMovieTranslation(v *validator.Validator, translation *MovieTranslation) {
+	v.Check(translation.Title != "", "title", "must be provided")
+	v.Check(len(translation.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(len(translation.Synopsis) <= 10_000, "synopsis", "must not be more than 10000 bytes long")
+}
+
+type TranslationModel struct {
+	DB *sql.DB
+}
+
+// Upsert creates the translation or replaces the movie's existing translation
+// for the same locale.
+func (m TranslationModel) Upsert(translation *MovieTranslation) error {
+	query := `
+		INSERT INTO movie_translations (movie_id, locale, title, synopsis)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (movie_id, locale) DO UPDATE
+		SET title = EXCLUDED.title, synopsis = EXCLUDED.synopsis, updated_at = NOW()
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{translation.MovieID, translation.Locale, translation.Title, translation.Synopsis}
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.UpdatedAt)
+}
+
+func (m TranslationModel) Delete(movieID int64, locale string) error {
+	if movieID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_translations
+		WHERE movie_id = $1 AND locale = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, locale)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m TranslationModel) GetAllForMovie(movieID int64) ([]*MovieTranslation, error) {
+	query := `
+		SELECT movie_id, locale, title, synopsis, updated_at
+		FROM movie_translations
+		WHERE movie_id = $1
+		ORDER BY locale ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	translations := []*MovieTranslation{}
+
+	for rows.Next() {
+		var translation MovieTranslation
+		err := rows.Scan(
+			&translation.MovieID,
+			&translation.Locale,
+			&translation.Title,
+			&translation.Synopsis,
+			&translation.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		translations = append(translations, &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+// Localize replaces the titles of the movies with their translation into the
+// first of the given locales they have one for, keeping the original title in
+// OriginalTitle. It returns the locales which were used.
+func (m TranslationModel) Localize(movies []*Movie, locales []string) ([]string, error) {
+	if len(movies) == 0 || len(locales) == 0 {
+		return []string{}, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (movie_id) movie_id, locale, title, synopsis
+		FROM movie_translations
+		WHERE movie_id = ANY($1) AND locale = ANY($2)
+		ORDER BY movie_id, array_position($2, locale)`
+
+	byID := make(map[int64]*Movie, len(movies))
+	ids := make([]int64, len(movies))
+
+	for i, movie := range movies {
+		byID[movie.ID] = movie
+		ids[i] = movie.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(locales))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	used := []string{}
+
+	for rows.Next() {
+		var translation MovieTranslation
+		err := rows.Scan(&translation.MovieID, &translation.Locale, &translation.Title, &translation.Synopsis)
+		if err != nil {
+			return nil, err
+		}
+
+		movie := byID[translation.MovieID]
+		movie.OriginalTitle = movie.Title
+		movie.Title = translation.Title
+		movie.Synopsis = translation.Synopsis
+
+		if !validator.PermittedValue(translation.Locale, used...) {
+			used = append(used, translation.Locale)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return used, nil
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mwettste/greenlight/internal/validator"
+)
+
+var (
+	ErrDuplicateWatchlistEntry = errors.New("duplicate watchlist entry")
+)
+
+type WatchlistEntry struct {
+	UserID    int64     `json:"-"`
+	Movie     *Movie    `json:"movie"`
+	AddedAt   time.Time `json:"added_at"`
+	Watched   bool      `json:"watched"`
+	WatchedOn *Date     `json:"watched_on,omitempty"`
+}
+
+func ValidateWatchlistEntry(v *validator.Validator, entry *WatchlistEntry) {
+	if entry.WatchedOn != nil {
+		v.Check(!entry.WatchedOn.After(time.Now()), "watched_on", "must not be in the future")
+		v.Check(entry.WatchedOn.Year() >= 1888, "watched_on", "must be after 1888")
+	}
+}
+
+type WatchlistModel struct {
+	DB *sql.DB
+}
+
+func (m WatchlistModel) Insert(entry *WatchlistEntry) error {
+	query := `
+	INSERT INTO watchlist_entries (user_id, movie_id, watched_on)
+	VALUES ($1, $2, $3)
+	RETURNING added_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{entry.UserID, entry.Movie.ID, entry.WatchedOn}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&entry.AddedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "watchlist_entries_pkey"`:
+			return ErrDuplicateWatchlistEntry
+		default:
+			return err
+		}
+	}
+
+	entry.Watched = entry.WatchedOn != nil
+	return nil
+}
+
+func (m WatchlistModel) Get(userID, movieID int64) (*WatchlistEntry, error) {
+	if userID < 1 || movieID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT watchlist_entries.added_at, watchlist_entries.watched_on,
+			movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+			ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids, movies.version
+		FROM watchlist_entries
+		INNER JOIN movies ON movies.id = watchlist_entries.movie_id
+		LEFT JOIN LATERAL (` + ratingsSubquery + `) ratings ON true
+		LEFT JOIN LATERAL (` + imagesSubquery + `) images ON true
+		LEFT JOIN LATERAL (` + externalIDsSubquery + `) external_ids ON true
+		WHERE watchlist_entries.user_id = $1 AND watchlist_entries.movie_id = $2 AND movies.deleted_at IS NULL`
+
+	entry := WatchlistEntry{UserID: userID, Movie: &Movie{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(
+		&entry.AddedAt,
+		&entry.WatchedOn,
+		&entry.Movie.ID,
+		&entry.Movie.CreatedAt,
+		&entry.Movie.Title,
+		&entry.Movie.Year,
+		&entry.Movie.RuntimeMin,
+		pq.Array(&entry.Movie.Genres),
+		&entry.Movie.AverageRating,
+		&entry.Movie.RatingCount,
+		&entry.Movie.Poster,
+		&entry.Movie.Backdrop,
+		&entry.Movie.ExternalIDs,
+		&entry.Movie.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	entry.Watched = entry.WatchedOn != nil
+	return &entry, nil
+}
+
+func (m WatchlistModel) Update(entry *WatchlistEntry) error {
+	query := `
+		UPDATE watchlist_entries
+		SET watched_on = $1
+		WHERE user_id = $2 AND movie_id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, entry.WatchedOn, entry.UserID, entry.Movie.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	entry.Watched = entry.WatchedOn != nil
+	return nil
+}
+
+func (m WatchlistModel) Delete(userID, movieID int64) error {
+	if userID < 1 || movieID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM watchlist_entries
+		WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m WatchlistModel) GetAllForUser(userID int64, filters Filters) ([]*WatchlistEntry, FilterMetadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), watchlist_entries.added_at, watchlist_entries.watched_on,
+			movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+			ratings.rating, ratings.rating_count, images.poster, images.backdrop, external_ids.ids, movies.version
+		FROM watchlist_entries
+		INNER JOIN movies ON movies.id = watchlist_entries.movie_id
+		LEFT JOIN LATERAL (`+ratingsSubquery+`) ratings ON true
+		LEFT JOIN LATERAL (`+imagesSubquery+`) images ON true
+		LEFT JOIN LATERAL (`+externalIDsSubquery+`) external_ids ON true
+		WHERE watchlist_entries.user_id = $1 AND movies.deleted_at IS NULL
+		ORDER BY %s %s, movies.id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	defer rows.Close()
+	entries := []*WatchlistEntry{}
+	totalRecords := 0
+
+	for rows.Next() {
+		entry := WatchlistEntry{UserID: userID, Movie: &Movie{}}
+		err := rows.Scan(
+			&totalRecords,
+			&entry.AddedAt,
+			&entry.WatchedOn,
+			&entry.Movie.ID,
+			&entry.Movie.CreatedAt,
+			&entry.Movie.Title,
+			&entry.Movie.Year,
+			&entry.Movie.RuntimeMin,
+			pq.Array(&entry.Movie.Genres),
+			&entry.Movie.AverageRating,
+			&entry.Movie.RatingCount,
+			&entry.Movie.Poster,
+			&entry.Movie.Backdrop,
+			&entry.Movie.ExternalIDs,
+			&entry.Movie.Version,
+		)
+
+		if err != nil {
+			return nil, FilterMetadata{}, err
+		}
+
+		entry.Watched = entry.WatchedOn != nil
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, FilterMetadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// whereClause collects SQL conditions together with their arguments, so only
+// the filters that were actually requested end up in the query.
+type whereClause struct {
+	conditions []string
+	args       []interface{}
+}
+
+// add appends a condition whose placeholders are written as $%d, one for each
+// of the given arguments in order.
+func (w *whereClause) add(condition string, args ...interface{}) {
+	positions := make([]interface{}, len(args))
+	for i, arg := range args {
+		positions[i] = w.bind(arg)
+	}
+
+	w.conditions = append(w.conditions, fmt.Sprintf(condition, positions...))
+}
+
+// bind appends an argument without a condition and returns its placeholder
+// position, for arguments used elsewhere in the query (e.g. in a join).
+func (w *whereClause) bind(arg interface{}) int {
+	w.args = append(w.args, arg)
+	return len(w.args)
+}
+
+// next returns the placeholder position for an argument appended after the conditions.
+func (w *whereClause) next() int {
+	return len(w.args) + 1
+}
+
+func (w *whereClause) String() string {
+	if len(w.conditions) == 0 {
+		return "true"
+	}
+
+	return strings.Join(w.conditions, " AND ")
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Storage stores objects such as uploaded images under slash separated keys.
+type Storage interface {
+	Put(key string, content io.Reader) error
+	Open(key string) (io.ReadSeekCloser, time.Time, error)
+	// DeleteAll deletes all objects whose keys start with the given prefix,
+	// which must end with a slash.
+	DeleteAll(prefix string) error
+}
+
+// Local stores objects as files in a directory on the local filesystem.
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) Local {
+	return Local{root: root}
+}
+
+func (l Local) Put(key string, content io.Reader) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// the object is written to a temporary file first, so that readers never
+	// see a partially written file
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, content)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func (l Local) Open(key string) (io.ReadSeekCloser, time.Time, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, time.Time{}, ErrNotFound
+		}
+		return nil, time.Time{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, time.Time{}, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	return file, info.ModTime(), nil
+}
+
+func (l Local) DeleteAll(prefix string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		return ErrInvalidKey
+	}
+
+	path, err := l.path(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(path)
+}
+
+// path maps a key to a file below the root directory. Keys must not be able
+// to escape it, so empty and relative path segments are rejected.
+func (l Local) path(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsRune(segment, filepath.Separator) {
+			return "", ErrInvalidKey
+		}
+	}
+
+	return filepath.Join(append([]string{l.root}, segments...)...), nil
+}
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = 'reviews:write';
\ No newline at end of file
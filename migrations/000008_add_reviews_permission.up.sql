@@ -0,0 +1,10 @@
+INSERT INTO permissions(code)
+VALUES
+    ('reviews:write');
+
+-- every user who can read movies so far gets to review them as well
+INSERT INTO users_permissions
+SELECT users_permissions.user_id, (SELECT id FROM permissions WHERE code = 'reviews:write')
+FROM users_permissions
+INNER JOIN permissions ON users_permissions.permission_id = permissions.id
+WHERE permissions.code = 'movies:read';
\ No newline at end of file
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    version integer NOT NULL,
+    operation text NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    title text NOT NULL,
+    year integer NOT NULL,
+    runtime integer NOT NULL,
+    genres text[] NOT NULL
+);
+
+ALTER TABLE movie_revisions ADD CONSTRAINT movie_revisions_operation_check CHECK (operation IN ('insert', 'update', 'delete', 'restore'));
+
+-- deletes and restores keep the version, so only inserts and updates identify a version
+CREATE UNIQUE INDEX IF NOT EXISTS movie_revisions_movie_id_version_idx ON movie_revisions (movie_id, version) WHERE operation IN ('insert', 'update');
+
+-- record the current state of existing movies as their first known revision
+INSERT INTO movie_revisions (created_at, movie_id, version, operation, title, year, runtime, genres)
+SELECT created_at, id, version, CASE WHEN version = 1 THEN 'insert' ELSE 'update' END, title, year, runtime, genres
+FROM movies;
\ No newline at end of file
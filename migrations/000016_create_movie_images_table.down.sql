@@ -0,0 +1 @@
+DROP TABLE IF EXISTS movie_images;
\ No newline at end of file
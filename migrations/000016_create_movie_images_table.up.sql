@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS movie_images (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    kind text NOT NULL,
+    token text NOT NULL,
+    width integer NOT NULL,
+    height integer NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, kind)
+);
+
+ALTER TABLE movie_images ADD CONSTRAINT movie_images_kind_check CHECK (kind IN ('poster', 'backdrop'));
\ No newline at end of file
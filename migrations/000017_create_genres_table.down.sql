@@ -0,0 +1,3 @@
+-- the genres of movies and revisions stay normalised to slugs, as their
+-- original spellings weren't kept by the up migration and can't be restored
+DROP TABLE IF EXISTS genres;
\ No newline at end of file
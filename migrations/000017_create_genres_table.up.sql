@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug text UNIQUE NOT NULL,
+    name text NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+-- normalise the free-form genres of existing movies to slugs, which merges
+-- spellings like "Sci-Fi" and "sci fi" while keeping the original order and
+-- letters outside a-z, as in "comédie".
+-- Genres without any letters or digits have no slug and are dropped; a movie
+-- left without genres gets "uncategorized" to satisfy genres_length_check.
+-- This can't be undone, see the down migration.
+UPDATE movies SET genres = coalesce(nullif(ARRAY(
+    SELECT slug
+    FROM (
+        SELECT trim(both '-' FROM regexp_replace(lower(genre), '[^[:alnum:]]+', '-', 'g')) AS slug, min(position) AS position
+        FROM unnest(movies.genres) WITH ORDINALITY AS movie_genres(genre, position)
+        GROUP BY 1
+    ) normalised
+    WHERE slug <> ''
+    ORDER BY position
+), '{}'), '{uncategorized}');
+
+UPDATE movie_revisions SET genres = coalesce(nullif(ARRAY(
+    SELECT slug
+    FROM (
+        SELECT trim(both '-' FROM regexp_replace(lower(genre), '[^[:alnum:]]+', '-', 'g')) AS slug, min(position) AS position
+        FROM unnest(movie_revisions.genres) WITH ORDINALITY AS revision_genres(genre, position)
+        GROUP BY 1
+    ) normalised
+    WHERE slug <> ''
+    ORDER BY position
+), '{}'), '{uncategorized}');
+
+INSERT INTO genres (slug, name)
+SELECT DISTINCT genre, initcap(replace(genre, '-', ' '))
+FROM movies, unnest(movies.genres) AS genre
+ON CONFLICT (slug) DO NOTHING;
\ No newline at end of file
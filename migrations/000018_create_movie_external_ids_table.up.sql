@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source text NOT NULL,
+    external_id text NOT NULL,
+    PRIMARY KEY (movie_id, source),
+    UNIQUE (source, external_id)
+);
+
+ALTER TABLE movie_external_ids ADD CONSTRAINT movie_external_ids_source_check CHECK (source IN ('imdb', 'tmdb', 'wikidata'));
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS movie_translations (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    locale text NOT NULL,
+    title text NOT NULL,
+    synopsis text NOT NULL DEFAULT '',
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS movie_translations_title_simple_idx ON movie_translations USING GIN (to_tsvector('simple', title));
+CREATE INDEX IF NOT EXISTS movie_translations_title_english_idx ON movie_translations USING GIN (to_tsvector('english', title));
+CREATE INDEX IF NOT EXISTS movie_translations_title_german_idx ON movie_translations USING GIN (to_tsvector('german', title));
+CREATE INDEX IF NOT EXISTS movie_translations_title_french_idx ON movie_translations USING GIN (to_tsvector('french', title));
\ No newline at end of file
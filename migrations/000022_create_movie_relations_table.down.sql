@@ -0,0 +1 @@
+DROP TABLE IF EXISTS movie_relations;
\ No newline at end of file
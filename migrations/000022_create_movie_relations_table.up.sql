@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS movie_relations (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    type text NOT NULL,
+    related_movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (movie_id, type, related_movie_id)
+);
+
+ALTER TABLE movie_relations ADD CONSTRAINT movie_relations_type_check CHECK (type IN ('sequel_of', 'remake_of', 'based_on', 'spin_off'));
+ALTER TABLE movie_relations ADD CONSTRAINT movie_relations_self_check CHECK (movie_id <> related_movie_id);
+
+CREATE INDEX IF NOT EXISTS movie_relations_related_movie_id_idx ON movie_relations (related_movie_id);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS movie_releases (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    type text NOT NULL,
+    release_date date NOT NULL,
+    certification text NOT NULL DEFAULT '',
+    UNIQUE (movie_id, country, type)
+);
+
+ALTER TABLE movie_releases ADD CONSTRAINT movie_releases_type_check CHECK (type IN ('theatrical', 'digital', 'physical'));
+
+CREATE INDEX IF NOT EXISTS movie_releases_country_release_date_idx ON movie_releases (country, release_date);
\ No newline at end of file